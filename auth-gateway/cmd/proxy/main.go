@@ -10,6 +10,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/claims"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/config"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/server"
@@ -39,16 +40,25 @@ func main() {
 		appConfig.HydraConfig.AdminURL,
 		appConfig.HydraConfig.PublicURL,
 		appConfig.KratosConfig.PublicURL,
+		appConfig.KratosConfig.AdminURL,
 	)
 
 	if err != nil {
 		sugar.Fatalf("Failed to create clients: %v", err)
 	}
 
-	authService := service.NewAuthServiceKratos(clients.KratosPublic)
-	oauth2Service := service.NewOAuth2ServiceHydra(clients.HydraPublic, clients.HydraAdmin)
+	claimsMapping, err := claims.ParseMapping(appConfig.ClaimsConfig.Mapping)
 
-	router := server.NewRouter(appConfig, authService, oauth2Service, logger)
+	if err != nil {
+		sugar.Fatalf("Failed to parse claims mapping: %v", err)
+	}
+
+	authService := service.NewAuthServiceKratos(clients.KratosPublic, clients.KratosAdmin)
+	oauth2Service := service.NewOAuth2ServiceHydra(clients.HydraPublic, clients.HydraAdmin, appConfig.LoginConfig)
+
+	clientService := service.NewClientServiceHydra(clients.HydraAdmin, appConfig.AdminConfig.ClientPolicy)
+
+	router := server.NewRouter(appConfig, authService, oauth2Service, clientService, claimsMapping, logger)
 
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", appConfig.ServerConfig.Port),
@@ -0,0 +1,90 @@
+// Package cache provides a small in-memory cache with per-entry expiry.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTL is a concurrency-safe map whose entries expire individually.
+// Expired entries are dropped on read and pruned on write once the cache
+// grows past maxEntries.
+type TTL[K comparable, V any] struct {
+	mu         sync.Mutex
+	entries    map[K]entry[V]
+	maxEntries int
+	now        func() time.Time
+}
+
+func NewTTL[K comparable, V any](maxEntries int) *TTL[K, V] {
+	return &TTL[K, V]{
+		entries:    make(map[K]entry[V]),
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	if !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+// Set stores the value until expiresAt. Values that already expired are
+// not stored.
+func (c *TTL[K, V]) Set(key K, value V, expiresAt time.Time) {
+	now := c.now()
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		c.prune(now)
+	}
+
+	// Still full after pruning, make room by dropping an arbitrary entry.
+	if c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+
+	c.entries[key] = entry[V]{value: value, expiresAt: expiresAt}
+}
+
+func (c *TTL[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+func (c *TTL[K, V]) prune(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+}
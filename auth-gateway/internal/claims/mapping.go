@@ -0,0 +1,161 @@
+// Package claims maps identity data to ID token and access token claims
+// based on the scopes granted during consent.
+package claims
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+)
+
+type Target string
+
+const (
+	TargetIDToken     Target = "id_token"
+	TargetAccessToken Target = "access_token"
+	TargetBoth        Target = "both"
+)
+
+// Rule copies the identity value found at Path into Claim.
+//
+// Path is dot separated and starts at the identity, for example
+// "traits.email" or "verifiable_addresses.0.verified".
+type Rule struct {
+	Claim  string `json:"claim"`
+	Path   string `json:"path"`
+	Target Target `json:"target,omitempty"`
+}
+
+// Mapping holds the rules for every scope.
+type Mapping map[string][]Rule
+
+// DefaultMapping covers the standard OpenID Connect email and profile
+// scopes for the traits of the bundled identity schema.
+func DefaultMapping() Mapping {
+	return Mapping{
+		"email": {
+			{Claim: "email", Path: "traits.email"},
+			{Claim: "email_verified", Path: "verifiable_addresses.0.verified"},
+		},
+		"profile": {
+			{Claim: "given_name", Path: "traits.name.first"},
+			{Claim: "family_name", Path: "traits.name.last"},
+		},
+	}
+}
+
+// ParseMapping parses a JSON mapping, for example
+//
+//	{"email": [{"claim": "email", "path": "traits.email"}]}
+//
+// An empty string returns the DefaultMapping.
+func ParseMapping(raw string) (Mapping, error) {
+	if strings.TrimSpace(raw) == "" {
+		return DefaultMapping(), nil
+	}
+
+	var mapping Mapping
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		return nil, fmt.Errorf("invalid claims mapping: %w", err)
+	}
+
+	for scope, rules := range mapping {
+		for i, rule := range rules {
+			if rule.Claim == "" || rule.Path == "" {
+				return nil, fmt.Errorf("invalid claims mapping: rule %d of scope %q needs claim and path", i, scope)
+			}
+
+			switch rule.Target {
+			case "":
+				mapping[scope][i].Target = TargetIDToken
+			case TargetIDToken, TargetAccessToken, TargetBoth:
+			default:
+				return nil, fmt.Errorf("invalid claims mapping: unknown target %q in scope %q", rule.Target, scope)
+			}
+		}
+	}
+
+	return mapping, nil
+}
+
+// Resolve returns the ID token and access token claims for the granted
+// scopes. Rules whose path does not exist on the identity are skipped.
+func (m Mapping) Resolve(identity model.Identity, scopes []string) (idToken map[string]any, accessToken map[string]any) {
+	idToken = make(map[string]any)
+	accessToken = make(map[string]any)
+	doc := identityDocument(identity)
+
+	for _, scope := range scopes {
+		for _, rule := range m[scope] {
+			value, ok := lookup(doc, rule.Path)
+			if !ok {
+				continue
+			}
+
+			switch rule.Target {
+			case TargetAccessToken:
+				accessToken[rule.Claim] = value
+			case TargetBoth:
+				idToken[rule.Claim] = value
+				accessToken[rule.Claim] = value
+			default:
+				idToken[rule.Claim] = value
+			}
+		}
+	}
+
+	return idToken, accessToken
+}
+
+// identityDocument turns the identity into the generic structure the rule
+// paths are evaluated against.
+func identityDocument(identity model.Identity) map[string]any {
+	addresses := make([]any, 0, len(identity.VerifiableAddresses))
+	for _, address := range identity.VerifiableAddresses {
+		addresses = append(addresses, map[string]any{
+			"value":    address.Value,
+			"via":      address.Via,
+			"verified": address.Verified,
+		})
+	}
+
+	return map[string]any{
+		"id":                   identity.ID,
+		"schema_id":            identity.SchemaID,
+		"traits":               identity.Traits,
+		"metadata_public":      identity.MetadataPublic,
+		"verifiable_addresses": addresses,
+	}
+}
+
+func lookup(doc any, path string) (any, bool) {
+	current := doc
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+
+	if current == nil {
+		return nil, false
+	}
+
+	return current, true
+}
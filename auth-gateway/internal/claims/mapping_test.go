@@ -0,0 +1,143 @@
+package claims
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+)
+
+func testIdentity() model.Identity {
+	return model.Identity{
+		ID: "identity-id",
+		Traits: map[string]any{
+			"email": "jane@example.com",
+			"name": map[string]any{
+				"first": "Jane",
+				"last":  "Doe",
+			},
+		},
+		VerifiableAddresses: []model.VerifiableAddress{
+			{Value: "jane@example.com", Via: "email", Verified: true},
+		},
+	}
+}
+
+func TestDefaultMappingResolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		scopes      []string
+		idToken     map[string]any
+		accessToken map[string]any
+	}{
+		{
+			name:        "no scopes",
+			scopes:      nil,
+			idToken:     map[string]any{},
+			accessToken: map[string]any{},
+		},
+		{
+			name:   "email",
+			scopes: []string{"openid", "email"},
+			idToken: map[string]any{
+				"email":          "jane@example.com",
+				"email_verified": true,
+			},
+			accessToken: map[string]any{},
+		},
+		{
+			name:   "profile",
+			scopes: []string{"openid", "profile"},
+			idToken: map[string]any{
+				"given_name":  "Jane",
+				"family_name": "Doe",
+			},
+			accessToken: map[string]any{},
+		},
+		{
+			name:        "unknown scope",
+			scopes:      []string{"offline_access"},
+			idToken:     map[string]any{},
+			accessToken: map[string]any{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idToken, accessToken := DefaultMapping().Resolve(testIdentity(), tt.scopes)
+
+			if !reflect.DeepEqual(idToken, tt.idToken) {
+				t.Errorf("id token claims = %v, want %v", idToken, tt.idToken)
+			}
+
+			if !reflect.DeepEqual(accessToken, tt.accessToken) {
+				t.Errorf("access token claims = %v, want %v", accessToken, tt.accessToken)
+			}
+		})
+	}
+}
+
+func TestResolveSkipsMissingPaths(t *testing.T) {
+	identity := testIdentity()
+	identity.Traits = map[string]any{"email": "jane@example.com"}
+	identity.VerifiableAddresses = nil
+
+	idToken, _ := DefaultMapping().Resolve(identity, []string{"email", "profile"})
+	want := map[string]any{"email": "jane@example.com"}
+
+	if !reflect.DeepEqual(idToken, want) {
+		t.Errorf("id token claims = %v, want %v", idToken, want)
+	}
+}
+
+func TestResolveTargets(t *testing.T) {
+	mapping, err := ParseMapping(`{
+		"roles": [
+			{"claim": "id", "path": "id"},
+			{"claim": "email", "path": "traits.email", "target": "access_token"},
+			{"claim": "first", "path": "traits.name.first", "target": "both"}
+		]
+	}`)
+
+	if err != nil {
+		t.Fatalf("ParseMapping() error = %v", err)
+	}
+
+	idToken, accessToken := mapping.Resolve(testIdentity(), []string{"roles"})
+
+	wantIDToken := map[string]any{"id": "identity-id", "first": "Jane"}
+	wantAccessToken := map[string]any{"email": "jane@example.com", "first": "Jane"}
+
+	if !reflect.DeepEqual(idToken, wantIDToken) {
+		t.Errorf("id token claims = %v, want %v", idToken, wantIDToken)
+	}
+
+	if !reflect.DeepEqual(accessToken, wantAccessToken) {
+		t.Errorf("access token claims = %v, want %v", accessToken, wantAccessToken)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "empty uses default", raw: "  "},
+		{name: "valid", raw: `{"email": [{"claim": "email", "path": "traits.email"}]}`},
+		{name: "invalid json", raw: `{`, wantErr: true},
+		{name: "missing claim", raw: `{"email": [{"path": "traits.email"}]}`, wantErr: true},
+		{name: "missing path", raw: `{"email": [{"claim": "email"}]}`, wantErr: true},
+		{name: "unknown target", raw: `{"email": [{"claim": "email", "path": "traits.email", "target": "userinfo"}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMapping(tt.raw)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
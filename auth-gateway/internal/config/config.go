@@ -2,20 +2,29 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 )
 
 type AppConfig struct {
-	DevMode      bool         `env:"DEV" envDefault:"false"`
-	ServerConfig ServerConfig `envPrefix:"SERVER_"`
-	HydraConfig  HydraConfig  `envPrefix:"HYDRA_"`
-	KratosConfig KratosConfig `envPrefix:"KRATOS_"`
+	DevMode           bool              `env:"DEV" envDefault:"false"`
+	ServerConfig      ServerConfig      `envPrefix:"SERVER_"`
+	HydraConfig       HydraConfig       `envPrefix:"HYDRA_"`
+	KratosConfig      KratosConfig      `envPrefix:"KRATOS_"`
+	LoginConfig       LoginConfig       `envPrefix:"LOGIN_"`
+	ClaimsConfig      ClaimsConfig      `envPrefix:"CLAIMS_"`
+	AdminConfig       AdminConfig       `envPrefix:"ADMIN_"`
+	MFAConfig         MFAConfig         `envPrefix:"MFA_"`
+	ForwardAuthConfig ForwardAuthConfig `envPrefix:"FORWARD_AUTH_"`
 }
 
 type ServerConfig struct {
 	Port int `env:"PORT" envDefault:"9941"`
+	// CORSAllowedOrigins must include the UI origin, which is also the
+	// passkey origin of Kratos.
+	CORSAllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS" envSeparator:"," envDefault:"http://localhost:5555"`
 }
 
 type HydraConfig struct {
@@ -25,6 +34,59 @@ type HydraConfig struct {
 
 type KratosConfig struct {
 	PublicURL string `env:"PUBLIC_URL"`
+	AdminURL  string `env:"ADMIN_URL"`
+}
+
+// ClaimsConfig configures which identity data ends up in the tokens.
+// Mapping is a JSON object of scope to claim rules, see claims.ParseMapping.
+type ClaimsConfig struct {
+	Mapping string `env:"MAPPING"`
+}
+
+// LoginConfig holds the defaults used when accepting Hydra login challenges.
+type LoginConfig struct {
+	// Remember is used when the user did not choose "remember me" explicitly.
+	Remember              bool          `env:"REMEMBER" envDefault:"false"`
+	RememberFor           time.Duration `env:"REMEMBER_FOR" envDefault:"720h"`
+	ExtendSessionLifespan bool          `env:"EXTEND_SESSION_LIFESPAN" envDefault:"true"`
+	// OIDCReturnURL is the UI page Kratos sends the browser to after a
+	// social sign-in, the login challenge is added as query parameter. It
+	// must be one of the allowed return URLs of Kratos.
+	OIDCReturnURL string `env:"OIDC_RETURN_URL"`
+}
+
+// MFAConfig configures which logins need a second factor, see
+// model.AALPolicy.
+type MFAConfig struct {
+	AAL2Clients   []string `env:"AAL2_CLIENTS" envSeparator:","`
+	AAL2ACRValues []string `env:"AAL2_ACR_VALUES" envSeparator:"," envDefault:"aal2"`
+}
+
+// ForwardAuthConfig configures the forward-auth endpoint.
+type ForwardAuthConfig struct {
+	// CacheTTL is the longest an introspection result is reused, so a
+	// revoked token stops passing after at most this long. Zero disables
+	// the cache.
+	CacheTTL time.Duration `env:"CACHE_TTL" envDefault:"30s"`
+}
+
+// AdminConfig configures the admin API. The API is disabled when APIKey is
+// empty.
+type AdminConfig struct {
+	APIKey       string       `env:"API_KEY"`
+	ClientPolicy ClientPolicy `envPrefix:"CLIENT_"`
+}
+
+// ClientPolicy restricts what OAuth2 clients may be registered through the
+// admin API.
+type ClientPolicy struct {
+	AllowedGrantTypes    []string `env:"ALLOWED_GRANT_TYPES" envSeparator:"," envDefault:"authorization_code,refresh_token,client_credentials"`
+	AllowedResponseTypes []string `env:"ALLOWED_RESPONSE_TYPES" envSeparator:"," envDefault:"code"`
+	// AllowedRedirectHosts limits redirect URI hosts, empty allows any host.
+	AllowedRedirectHosts []string `env:"ALLOWED_REDIRECT_HOSTS" envSeparator:","`
+	// AllowInsecureRedirects allows http redirect URIs for hosts other
+	// than localhost and 127.0.0.1.
+	AllowInsecureRedirects bool `env:"ALLOW_INSECURE_REDIRECTS" envDefault:"false"`
 }
 
 func LoadConfig() (*AppConfig, error) {
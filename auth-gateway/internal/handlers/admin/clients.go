@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (h *Handler) ListClients(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	form := model.ListClientsForm{PageToken: r.URL.Query().Get("page_token")}
+
+	if pageSize := r.URL.Query().Get("page_size"); pageSize != "" {
+		size, err := strconv.ParseInt(pageSize, 10, 64)
+
+		if err != nil || size <= 0 {
+			response.WriteError(w, response.NewValidation(map[string]string{"page_size": "must be a positive number"}))
+			return
+		}
+
+		form.PageSize = size
+	}
+
+	clients, err := h.clients.ListClients(r.Context(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, clients)
+}
+
+func (h *Handler) GetClient(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	client, err := h.clients.GetClient(r.Context(), ps.ByName("id"))
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, client)
+}
+
+// CreateClient registers a new OAuth2 client. The response is the only
+// place the client secret is ever returned.
+func (h *Handler) CreateClient(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.ClientForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	client, err := h.clients.CreateClient(r.Context(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusCreated, client)
+}
+
+func (h *Handler) UpdateClient(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.ClientForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	client, err := h.clients.UpdateClient(r.Context(), ps.ByName("id"), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, client)
+}
+
+func (h *Handler) DeleteClient(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.clients.DeleteClient(r.Context(), ps.ByName("id")); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, nil)
+}
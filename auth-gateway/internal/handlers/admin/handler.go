@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/service"
+	"github.com/julienschmidt/httprouter"
+)
+
+type Handler struct {
+	clients service.ClientService
+	apiKey  string
+}
+
+func NewHandler(clients service.ClientService, apiKey string) *Handler {
+	return &Handler{clients: clients, apiKey: apiKey}
+}
+
+func (h *Handler) RegisterRoutes(r *httprouter.Router) {
+	r.GET("/admin/clients", h.authenticate(h.ListClients))
+	r.POST("/admin/clients", h.authenticate(h.CreateClient))
+	r.GET("/admin/clients/:id", h.authenticate(h.GetClient))
+	r.PUT("/admin/clients/:id", h.authenticate(h.UpdateClient))
+	r.DELETE("/admin/clients/:id", h.authenticate(h.DeleteClient))
+}
+
+// authenticate only lets requests through that carry the admin API key as
+// a bearer token
+func (h *Handler) authenticate(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.apiKey)) != 1 {
+			response.WriteError(w, response.ErrInvalidCredentials)
+			return
+		}
+
+		next(w, r, ps)
+	}
+}
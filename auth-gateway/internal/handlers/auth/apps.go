@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+)
+
+// currentSession resolves the Kratos session of the browser. It writes the
+// error response itself and reports false if there is no valid session.
+func (h *Handler) currentSession(w http.ResponseWriter, r *http.Request) (model.Session, bool) {
+	session, outCookies, err := h.idp.ToSession(r.Context(), r.Cookies())
+
+	if err != nil {
+		response.WriteError(w, err)
+		return model.Session{}, false
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	return session, true
+}
+
+// ListConnectedApps lists the clients the current identity granted access to
+func (h *Handler) ListConnectedApps(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	session, ok := h.currentSession(w, r)
+	if !ok {
+		return
+	}
+
+	apps, err := h.oauth2.ListConsentSessions(r.Context(), session.IdentityID)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, apps)
+}
+
+// RevokeConnectedApp revokes the consent and tokens of a single client
+func (h *Handler) RevokeConnectedApp(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	session, ok := h.currentSession(w, r)
+	if !ok {
+		return
+	}
+
+	clientID := ps.ByName("client_id")
+
+	if clientID == "" {
+		response.WriteError(w, response.NewValidation(map[string]string{"client_id": "required"}))
+		return
+	}
+
+	if err := h.oauth2.RevokeConsentSessions(r.Context(), session.IdentityID, clientID); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, nil)
+}
+
+// RevokeAllConnectedApps revokes the consent and tokens of every client
+func (h *Handler) RevokeAllConnectedApps(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	session, ok := h.currentSession(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.oauth2.RevokeConsentSessions(r.Context(), session.IdentityID, ""); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, nil)
+}
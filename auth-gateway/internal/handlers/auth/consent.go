@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// GetConsentRequest returns the Hydra consent request so the UI can show
+// the client and the requested scopes
+func (h *Handler) GetConsentRequest(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	consentChallenge := r.URL.Query().Get("consent_challenge")
+
+	consent, err := h.oauth2.GetConsentRequest(r.Context(), consentChallenge)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, consent)
+}
+
+// AcceptConsentChallenge grants the chosen scopes and fills the token
+// claims from the identity according to the claims mapping
+func (h *Handler) AcceptConsentChallenge(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	consentChallenge := r.URL.Query().Get("consent_challenge")
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.AcceptOAuth2ConsentChallengeForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	form.Challenge = consentChallenge
+	consent, err := h.oauth2.GetConsentRequest(r.Context(), consentChallenge)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	identity, err := h.idp.GetIdentity(r.Context(), consent.Subject)
+
+	if err != nil {
+		logger.Error("failed to get identity for consent", zap.String("subject", consent.Subject), zap.Error(err))
+		response.WriteError(w, err)
+		return
+	}
+
+	// Only what the client asked for can be granted, the UI picks a subset.
+	form.GrantScope = intersect(form.GrantScope, consent.RequestedScope)
+	form.GrantAudience = intersect(form.GrantAudience, consent.RequestedAudience)
+
+	form.IDTokenClaims, form.AccessTokenClaims = h.claims.Resolve(identity, form.GrantScope)
+	redirect, outCookies, err := h.oauth2.AcceptConsentChallenge(r.Context(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, redirect)
+}
+
+// intersect returns the granted values that were also requested, in the
+// order they were granted.
+func intersect(granted, requested []string) []string {
+	result := make([]string, 0, len(granted))
+
+	for _, value := range granted {
+		if slices.Contains(requested, value) && !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+func (h *Handler) RejectConsentChallenge(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	consentChallenge := r.URL.Query().Get("consent_challenge")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.RejectOAuth2ConsentChallengeForm
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &form); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+	}
+
+	form.Challenge = consentChallenge
+	redirect, outCookies, err := h.oauth2.RejectConsentChallenge(r.Context(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, redirect)
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		name      string
+		granted   []string
+		requested []string
+		want      []string
+	}{
+		{
+			name:      "subset",
+			granted:   []string{"openid", "email"},
+			requested: []string{"openid", "email", "profile"},
+			want:      []string{"openid", "email"},
+		},
+		{
+			name:      "not requested",
+			granted:   []string{"openid", "admin"},
+			requested: []string{"openid"},
+			want:      []string{"openid"},
+		},
+		{
+			name:      "duplicates",
+			granted:   []string{"openid", "openid"},
+			requested: []string{"openid"},
+			want:      []string{"openid"},
+		},
+		{
+			name:      "nothing requested",
+			granted:   []string{"openid"},
+			requested: nil,
+			want:      []string{},
+		},
+		{
+			name:      "nothing granted",
+			granted:   nil,
+			requested: []string{"openid"},
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intersect(tt.granted, tt.requested); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("intersect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
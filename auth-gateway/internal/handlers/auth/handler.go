@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/claims"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/service"
 	"github.com/julienschmidt/httprouter"
 )
@@ -8,16 +10,76 @@ import (
 type Handler struct {
 	idp    service.IDPService
 	oauth2 service.OAuth2Service
+	claims claims.Mapping
+	aal    model.AALPolicy
+	// oidcReturnURL is the UI page browsers return to after a social
+	// sign-in.
+	oidcReturnURL string
 }
 
-func NewHandler(idp service.IDPService, oauth2 service.OAuth2Service) *Handler {
-	return &Handler{idp: idp, oauth2: oauth2}
+func NewHandler(
+	idp service.IDPService,
+	oauth2 service.OAuth2Service,
+	claimsMapping claims.Mapping,
+	aalPolicy model.AALPolicy,
+	oidcReturnURL string,
+) *Handler {
+	return &Handler{idp: idp, oauth2: oauth2, claims: claimsMapping, aal: aalPolicy, oidcReturnURL: oidcReturnURL}
 }
 
 func (h *Handler) RegisterRoutes(r *httprouter.Router) {
 	r.GET("/oauth2/auth", h.CreateOAuth2Flow)
+	r.POST("/oauth2/device/verify", h.VerifyDeviceCode)
 	r.GET("/login/browser", h.CreateLoginFlow)
 	r.GET("/login/flows", h.GetLoginFlow)
 	r.POST("/login/flows/email", h.SendLoginEmailCode)
 	r.POST("/login/flows/email/submit", h.SubmitLoginEmailCode)
+	r.POST("/login/flows/totp/submit", h.SubmitLoginTOTP)
+	r.POST("/login/flows/lookup-secret/submit", h.SubmitLoginLookupSecret)
+	r.POST("/login/flows/passkey/submit", h.SubmitLoginPasskey)
+	r.POST("/login/flows/oidc", h.StartLoginOIDC)
+	r.POST("/login/flows/oidc/complete", h.CompleteLoginOIDC)
+	r.POST("/login/reject", h.RejectLoginChallenge)
+	r.GET("/registration/browser", h.CreateRegistrationFlow)
+	r.GET("/registration/flows", h.GetRegistrationFlow)
+	r.POST("/registration/flows/email", h.SendRegistrationCode)
+	r.POST("/registration/flows/email/submit", h.SubmitRegistrationCode)
+	r.GET("/recovery/browser", h.CreateRecoveryFlow)
+	r.GET("/recovery/flows", h.GetRecoveryFlow)
+	r.POST("/recovery/flows/email", h.SendRecoveryCode)
+	r.POST("/recovery/flows/email/submit", h.SubmitRecoveryCode)
+	r.GET("/verification/browser", h.CreateVerificationFlow)
+	r.GET("/verification/flows", h.GetVerificationFlow)
+	r.POST("/verification/flows/email", h.SendVerificationCode)
+	r.POST("/verification/flows/email/submit", h.SubmitVerificationCode)
+	r.GET("/settings/browser", h.CreateSettingsFlow)
+	r.GET("/settings/flows", h.GetSettingsFlow)
+	r.POST("/settings/flows/profile", h.UpdateSettingsProfile)
+	r.POST("/settings/flows/totp", h.LinkSettingsTOTP)
+	r.POST("/settings/flows/totp/unlink", h.UnlinkSettingsTOTP)
+	r.POST("/settings/flows/lookup-secret/generate", h.updateSettingsLookupSecret(h.idp.GenerateSettingsLookupSecret))
+	r.POST("/settings/flows/lookup-secret/reveal", h.updateSettingsLookupSecret(h.idp.RevealSettingsLookupSecret))
+	r.POST("/settings/flows/lookup-secret/confirm", h.updateSettingsLookupSecret(h.idp.ConfirmSettingsLookupSecret))
+	r.POST("/settings/flows/passkey", h.RegisterSettingsPasskey)
+	r.POST("/settings/flows/passkey/remove", h.RemoveSettingsPasskey)
+	r.GET("/native/login/api", native(h.CreateLoginFlow))
+	r.GET("/native/login/flows", native(h.GetLoginFlow))
+	r.POST("/native/login/flows/email", native(h.SendLoginEmailCode))
+	r.POST("/native/login/flows/email/submit", native(h.SubmitLoginEmailCode))
+	r.POST("/native/login/flows/totp/submit", native(h.SubmitLoginTOTP))
+	r.POST("/native/login/flows/lookup-secret/submit", native(h.SubmitLoginLookupSecret))
+	r.GET("/native/registration/api", native(h.CreateRegistrationFlow))
+	r.GET("/native/registration/flows", native(h.GetRegistrationFlow))
+	r.POST("/native/registration/flows/email", native(h.SendRegistrationCode))
+	r.POST("/native/registration/flows/email/submit", native(h.SubmitRegistrationCode))
+	r.POST("/native/logout", native(h.NativeLogout))
+	r.GET("/consent", h.GetConsentRequest)
+	r.POST("/consent/accept", h.AcceptConsentChallenge)
+	r.POST("/consent/reject", h.RejectConsentChallenge)
+	r.GET("/logout", h.GetLogoutRequest)
+	r.POST("/logout/accept", h.AcceptLogoutChallenge)
+	r.POST("/logout/reject", h.RejectLogoutChallenge)
+	r.GET("/connected-apps", h.ListConnectedApps)
+	r.DELETE("/connected-apps", h.RevokeAllConnectedApps)
+	r.DELETE("/connected-apps/:client_id", h.RevokeConnectedApp)
 }
@@ -1,33 +1,255 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/service"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
 )
 
-// CreateLoginFlow creates a new login flow in Kratos
+// CreateLoginFlow creates a new login flow in Kratos. If Hydra or Kratos
+// already know the user, the login challenge is accepted right away and
+// the flow only carries the redirect. The OIDC parameters of the
+// authorization request (prompt, max_age, login_hint, ...) are honoured.
 func (h *Handler) CreateLoginFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	loginChallenge := r.URL.Query().Get("challenge")
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	loginRequest, err := h.oauth2.GetLoginRequest(r.Context(), loginChallenge)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	oidc := loginRequest.OIDC
+	accept, flowForm := h.knownUserLogin(w, r, &loginRequest)
+
+	if accept != nil {
+		redirect, outCookies, err := h.oauth2.AcceptOAuth2LoginChallenge(r.Context(), accept)
+
+		if err != nil {
+			logger.Error("failed to accept oauth2 login challenge", zap.Error(err))
+			response.WriteError(w, err)
+			return
+		}
+
+		util.ForwardSetCookieHeader(outCookies, w)
+		response.WriteData(w, http.StatusOK, model.LoginFlow{RedirectTo: redirect.RedirectTo})
+		return
+	}
+
+	// The client asked us not to show any UI, but the user has to log in.
+	if oidc.HasPrompt("none") {
+		if redirectTo, ok := h.rejectLoginRequired(w, r, loginChallenge); ok {
+			response.WriteData(w, http.StatusOK, model.LoginFlow{RedirectTo: redirectTo})
+		}
+
+		return
+	}
 
-	flow, outCookies, err := h.idp.CreateLoginFlow(r.Context(), loginChallenge, r.Cookies())
+	flowForm.ReturnTo = h.oidcReturnTo(loginChallenge)
+	flow, outCookies, err := h.idp.CreateLoginFlow(r.Context(), loginChallenge, r.Cookies(), &flowForm)
 
 	if err != nil {
 		response.WriteError(w, err)
 		return
 	}
 
+	if flow.Identifier == "" {
+		flow.Identifier = oidc.LoginHint
+	}
+
+	flow.OIDC = &oidc
+
 	util.ForwardSetCookieHeader(outCookies, w)
 	response.WriteData(w, http.StatusOK, flow)
 }
 
+// rejectLoginRequired rejects the login challenge with login_required for
+// a prompt=none request whose user has to authenticate. It returns where
+// to send the browser, or false after writing the error.
+func (h *Handler) rejectLoginRequired(w http.ResponseWriter, r *http.Request, loginChallenge string) (string, bool) {
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	redirect, outCookies, err := h.oauth2.RejectOAuth2LoginChallenge(r.Context(), &model.RejectOAuth2LoginChallengeForm{
+		Challenge:        loginChallenge,
+		Error:            model.OAuth2ErrorLoginRequired,
+		ErrorDescription: "The user must authenticate, but prompt=none was requested",
+	})
+
+	if err != nil {
+		logger.Error("failed to reject oauth2 login challenge", zap.Error(err))
+		response.WriteError(w, err)
+		return "", false
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	return redirect.RedirectTo, true
+}
+
+// knownUserLogin returns the accept form if the user does not have to
+// authenticate again, either because Hydra skips the login or because the
+// browser has a Kratos session that satisfies prompt, max_age and the AAL
+// policy. Otherwise it returns how the login flow has to be created: with
+// refresh when the session must be re-authenticated, with aal2 when it
+// only lacks the second factor.
+func (h *Handler) knownUserLogin(w http.ResponseWriter, r *http.Request, loginRequest *model.LoginRequest) (accept *model.AcceptOAuth2LoginChallengeForm, flowForm model.CreateLoginFlowForm) {
+	logger := middleware.GetLoggerFrom(r.Context())
+	oidc := loginRequest.OIDC
+	requiredAAL := h.aal.RequiredAAL(loginRequest)
+
+	if oidc.HasPrompt("login") {
+		return nil, model.CreateLoginFlowForm{Refresh: true}
+	}
+
+	// Hydra does not tell how the skipped login was authenticated, so a
+	// login that needs a second factor always checks the session.
+	if loginRequest.Skip && requiredAAL == model.AAL1 {
+		return &model.AcceptOAuth2LoginChallengeForm{
+			Challenge:             loginRequest.Challenge,
+			Subject:               loginRequest.Subject,
+			ExtendSessionLifespan: true,
+		}, model.CreateLoginFlowForm{}
+	}
+
+	session, outCookies, err := h.idp.ToSession(r.Context(), r.Cookies())
+
+	if err != nil {
+		if !errors.Is(err, response.ErrUnauthorized) {
+			logger.Warn("failed to check identity session", zap.Error(err))
+		}
+
+		return nil, model.CreateLoginFlowForm{}
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+
+	if flowForm, ok := reauthentication(oidc, requiredAAL, session, false, time.Now()); !ok {
+		logger.Info("session cannot be used for the login",
+			zap.Time("authenticated_at", session.AuthenticatedAt),
+			zap.String("aal", session.AAL),
+			zap.Bool("refresh", flowForm.Refresh))
+		return nil, flowForm
+	}
+
+	return &model.AcceptOAuth2LoginChallengeForm{
+		Challenge: loginRequest.Challenge,
+		Subject:   session.IdentityID,
+		SessionID: session.ID,
+		ACR:       session.ACR(),
+		AMR:       session.AMR(),
+	}, model.CreateLoginFlowForm{}
+}
+
+// authenticatedLogin is the outcome of a submitted login or registration
+// flow.
+type authenticatedLogin struct {
+	session model.Session
+	// fresh is set when the user authenticated the session for this login
+	// request, which satisfies prompt=login and max_age.
+	fresh bool
+	// sessionToken is only set for native flows.
+	sessionToken string
+	// cookies are the request cookies with the new session cookie.
+	cookies  []*http.Cookie
+	remember *bool
+}
+
+// acceptLogin accepts the login challenge for a session the user signed in
+// with. When the session does not pass reauthentication, it answers with
+// ErrSessionRefreshRequired or ErrAAL2Required and the login flow to
+// complete as details instead; the challenge is accepted once that flow is
+// submitted.
+func (h *Handler) acceptLogin(w http.ResponseWriter, r *http.Request, loginChallenge string, login *authenticatedLogin) {
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	loginRequest, err := h.oauth2.GetLoginRequest(r.Context(), loginChallenge)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	requiredAAL := h.aal.RequiredAAL(&loginRequest)
+
+	if flowForm, ok := reauthentication(loginRequest.OIDC, requiredAAL, login.session, login.fresh, time.Now()); !ok {
+		ctx := r.Context()
+
+		// The new flow belongs to the session that was just signed in.
+		if login.sessionToken != "" {
+			ctx = service.WithNativeFlow(ctx, login.sessionToken)
+		}
+
+		flow, outCookies, err := h.idp.CreateLoginFlow(ctx, loginChallenge, login.cookies, &flowForm)
+
+		if err != nil {
+			logger.Error("failed to create login flow for the session", zap.Error(err))
+			response.WriteError(w, err)
+			return
+		}
+
+		flow.SessionToken = login.sessionToken
+		util.ForwardSetCookieHeader(outCookies, w)
+
+		if flowForm.Refresh {
+			logger.Info("login needs a fresh authentication", zap.String("client_id", loginRequest.Client.ID))
+			response.WriteError(w, response.WithDetails(response.ErrSessionRefreshRequired, flow))
+			return
+		}
+
+		logger.Info("login needs a second factor", zap.String("client_id", loginRequest.Client.ID))
+		response.WriteError(w, response.WithDetails(response.ErrAAL2Required, flow))
+		return
+	}
+
+	redirect, outCookies, err := h.oauth2.AcceptOAuth2LoginChallenge(r.Context(), &model.AcceptOAuth2LoginChallengeForm{
+		Challenge: loginChallenge,
+		Subject:   login.session.IdentityID,
+		SessionID: login.session.ID,
+		Remember:  login.remember,
+		ACR:       login.session.ACR(),
+		AMR:       login.session.AMR(),
+	})
+
+	if err != nil {
+		logger.Error("failed to accept oauth2 login challenge", zap.Error(err))
+		response.WriteError(w, err)
+		return
+	}
+
+	redirect.SessionToken = login.sessionToken
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, redirect)
+}
+
+// reauthentication checks a session against prompt, max_age and the AAL
+// policy of a login request. ok is false when the user has to complete
+// another login flow first, flowForm tells which: a refresh flow to
+// authenticate again, or an aal2 flow for the missing second factor.
+func reauthentication(oidc model.OIDCContext, requiredAAL string, session model.Session, fresh bool, now time.Time) (flowForm model.CreateLoginFlowForm, ok bool) {
+	if !fresh && (oidc.HasPrompt("login") || oidc.MaxAgeExceeded(session.AuthenticatedAt, now)) {
+		return model.CreateLoginFlowForm{Refresh: true}, false
+	}
+
+	if !session.Satisfies(requiredAAL) {
+		return model.CreateLoginFlowForm{AAL: requiredAAL}, false
+	}
+
+	return model.CreateLoginFlowForm{}, true
+}
+
 // GetLoginFlow gets a login flow from Kratos
 func (h *Handler) GetLoginFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	id := r.URL.Query().Get("id")
@@ -72,10 +294,24 @@ func (h *Handler) SendLoginEmailCode(w http.ResponseWriter, r *http.Request, _ h
 	response.WriteData(w, http.StatusOK, flow)
 }
 
+// SubmitLoginEmailCode signs the user in with the emailed code and accepts
+// the login challenge, or asks for a second factor first, see acceptLogin.
 func (h *Handler) SubmitLoginEmailCode(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var form model.SubmitLoginEmailCodeForm
+
+	h.submitLogin(w, r, &form, &form.RememberChoice, func(ctx context.Context, flowID string, cookies []*http.Cookie) (model.SubmitLoginResponse, []*http.Cookie, error) {
+		return h.idp.SubmitLoginEmailCode(ctx, flowID, cookies, &form)
+	})
+}
+
+// loginSubmission submits the decoded form of one login method.
+type loginSubmission func(ctx context.Context, flowID string, cookies []*http.Cookie) (model.SubmitLoginResponse, []*http.Cookie, error)
+
+// submitLogin decodes the body into form, submits it on the login flow and
+// accepts the login challenge with the signed in session.
+func (h *Handler) submitLogin(w http.ResponseWriter, r *http.Request, form any, remember *model.RememberChoice, submit loginSubmission) {
 	id := r.URL.Query().Get("id")
 	loginChallenge := r.URL.Query().Get("login_challenge")
-	logger := middleware.GetLoggerFrom(r.Context())
 
 	body, err := io.ReadAll(r.Body)
 
@@ -85,14 +321,13 @@ func (h *Handler) SubmitLoginEmailCode(w http.ResponseWriter, r *http.Request, _
 	}
 
 	r.Body.Close()
-	var form model.SubmitLoginEmailCodeForm
 
-	if err := json.Unmarshal(body, &form); err != nil {
+	if err := json.Unmarshal(body, form); err != nil {
 		response.WriteError(w, err)
 		return
 	}
 
-	submitRes, outCookies, err := h.idp.SubmitLoginEmailCode(r.Context(), id, r.Cookies(), &form)
+	submitRes, outCookies, err := submit(r.Context(), id, r.Cookies())
 
 	if err != nil {
 		response.WriteError(w, err)
@@ -101,13 +336,48 @@ func (h *Handler) SubmitLoginEmailCode(w http.ResponseWriter, r *http.Request, _
 
 	util.ForwardSetCookieHeader(outCookies, w)
 
-	redirect, outCookies, err := h.oauth2.AcceptOAuth2LoginChallenge(r.Context(), &model.AcceptOAuth2LoginChallengeForm{
-		Challenge: loginChallenge,
-		Subject:   submitRes.Session.ID,
+	// A refresh or step-up login, e.g. before a settings change, has no
+	// OAuth2 request to accept.
+	if loginChallenge == "" {
+		response.WriteData(w, http.StatusOK, submitRes)
+		return
+	}
+
+	h.acceptLogin(w, r, loginChallenge, &authenticatedLogin{
+		session:      submitRes.Session,
+		fresh:        true,
+		sessionToken: submitRes.SessionToken,
+		cookies:      util.MergeCookies(r.Cookies(), outCookies),
+		remember:     remember.Remember,
 	})
+}
+
+// RejectLoginChallenge aborts the OAuth2 flow and sends the user back to the
+// client with an OAuth2 error
+func (h *Handler) RejectLoginChallenge(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	loginChallenge := r.URL.Query().Get("login_challenge")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.RejectOAuth2LoginChallengeForm
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &form); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+	}
+
+	form.Challenge = loginChallenge
+	redirect, outCookies, err := h.oauth2.RejectOAuth2LoginChallenge(r.Context(), &form)
 
 	if err != nil {
-		logger.Error("failed to accept oauth2 login challenge", zap.Error(err))
 		response.WriteError(w, err)
 		return
 	}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+)
+
+func TestReauthentication(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	maxAge := int64(300)
+	zero := int64(0)
+
+	aal1Session := model.Session{AuthenticatedAt: now.Add(-time.Minute), AAL: model.AAL1}
+	oldSession := model.Session{AuthenticatedAt: now.Add(-time.Hour), AAL: model.AAL1}
+	aal2Session := model.Session{AuthenticatedAt: now.Add(-time.Minute), AAL: model.AAL2}
+
+	tests := []struct {
+		name        string
+		oidc        model.OIDCContext
+		requiredAAL string
+		session     model.Session
+		fresh       bool
+		wantForm    model.CreateLoginFlowForm
+		wantOK      bool
+	}{
+		{
+			name:        "existing session",
+			requiredAAL: model.AAL1,
+			session:     aal1Session,
+			wantOK:      true,
+		},
+		{
+			name:        "prompt login with existing session",
+			oidc:        model.OIDCContext{Prompt: []string{"login"}},
+			requiredAAL: model.AAL1,
+			session:     aal1Session,
+			wantForm:    model.CreateLoginFlowForm{Refresh: true},
+		},
+		{
+			name:        "prompt login with fresh session",
+			oidc:        model.OIDCContext{Prompt: []string{"login"}},
+			requiredAAL: model.AAL1,
+			session:     aal1Session,
+			fresh:       true,
+			wantOK:      true,
+		},
+		{
+			name:        "within max_age",
+			oidc:        model.OIDCContext{MaxAge: &maxAge},
+			requiredAAL: model.AAL1,
+			session:     aal1Session,
+			wantOK:      true,
+		},
+		{
+			name:        "max_age exceeded",
+			oidc:        model.OIDCContext{MaxAge: &maxAge},
+			requiredAAL: model.AAL1,
+			session:     oldSession,
+			wantForm:    model.CreateLoginFlowForm{Refresh: true},
+		},
+		{
+			name:        "max_age exceeded with fresh session",
+			oidc:        model.OIDCContext{MaxAge: &maxAge},
+			requiredAAL: model.AAL1,
+			session:     oldSession,
+			fresh:       true,
+			wantOK:      true,
+		},
+		{
+			name:        "max_age zero",
+			oidc:        model.OIDCContext{MaxAge: &zero},
+			requiredAAL: model.AAL1,
+			session:     aal1Session,
+			wantForm:    model.CreateLoginFlowForm{Refresh: true},
+		},
+		{
+			name:        "second factor missing",
+			requiredAAL: model.AAL2,
+			session:     aal1Session,
+			fresh:       true,
+			wantForm:    model.CreateLoginFlowForm{AAL: model.AAL2},
+		},
+		{
+			name:        "second factor present",
+			requiredAAL: model.AAL2,
+			session:     aal2Session,
+			wantOK:      true,
+		},
+		{
+			name:        "refresh comes before the second factor",
+			oidc:        model.OIDCContext{Prompt: []string{"login"}},
+			requiredAAL: model.AAL2,
+			session:     aal1Session,
+			wantForm:    model.CreateLoginFlowForm{Refresh: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form, ok := reauthentication(tt.oidc, tt.requiredAAL, tt.session, tt.fresh, now)
+
+			if ok != tt.wantOK {
+				t.Errorf("reauthentication() ok = %v, want %v", ok, tt.wantOK)
+			}
+
+			if form != tt.wantForm {
+				t.Errorf("reauthentication() form = %+v, want %+v", form, tt.wantForm)
+			}
+		})
+	}
+}
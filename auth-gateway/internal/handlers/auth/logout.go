@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// GetLogoutRequest returns the Hydra logout request so the UI can ask the
+// user to confirm the logout
+func (h *Handler) GetLogoutRequest(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logoutChallenge := r.URL.Query().Get("logout_challenge")
+
+	logout, err := h.oauth2.GetLogoutRequest(r.Context(), logoutChallenge)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, logout)
+}
+
+// AcceptLogoutChallenge accepts the Hydra logout challenge and ends the
+// Kratos session of the browser. The Kratos session is only ended once
+// Hydra accepted, so a failed accept does not leave the user half logged
+// out.
+func (h *Handler) AcceptLogoutChallenge(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logoutChallenge := r.URL.Query().Get("logout_challenge")
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	if logoutChallenge == "" {
+		response.WriteError(w, response.NewValidation(map[string]string{"logout_challenge": "required"}))
+		return
+	}
+
+	logoutFlow, outCookies, err := h.idp.CreateLogoutFlow(r.Context(), r.Cookies())
+	hasSession := true
+
+	switch {
+	case errors.Is(err, response.ErrUnauthorized):
+		// No Kratos session in this browser, only Hydra has to be logged out.
+		logger.Info("no identity session to log out")
+		hasSession = false
+	case err != nil:
+		logger.Error("failed to create logout flow", zap.Error(err))
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	cookies := util.MergeCookies(r.Cookies(), outCookies)
+
+	redirect, outCookies, err := h.oauth2.AcceptLogoutChallenge(r.Context(), logoutChallenge)
+
+	if err != nil {
+		logger.Error("failed to accept oauth2 logout challenge", zap.Error(err))
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+
+	if hasSession {
+		outCookies, err = h.idp.PerformLogout(r.Context(), logoutFlow.LogoutToken, cookies)
+
+		if err != nil {
+			logger.Error("failed to perform logout after accepting the oauth2 logout challenge", zap.Error(err))
+			response.WriteError(w, err)
+			return
+		}
+
+		util.ForwardSetCookieHeader(outCookies, w)
+	}
+
+	response.WriteData(w, http.StatusOK, redirect)
+}
+
+// NativeLogout ends the session of the app's session token. Apps usually
+// log out without Hydra; when the app was sent here by an OIDC logout, the
+// logout_challenge is accepted first, like AcceptLogoutChallenge does for
+// browsers, and the redirect is returned.
+func (h *Handler) NativeLogout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logoutChallenge := r.URL.Query().Get("logout_challenge")
+	sessionToken := sessionTokenFrom(r)
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	if sessionToken == "" {
+		logger.Info("no session token to log out")
+		response.WriteError(w, response.ErrUnauthorized)
+		return
+	}
+
+	var redirect *model.AcceptOAuth2LogoutChallengeResponse
+
+	if logoutChallenge != "" {
+		accepted, _, err := h.oauth2.AcceptLogoutChallenge(r.Context(), logoutChallenge)
+
+		if err != nil {
+			logger.Error("failed to accept oauth2 logout challenge", zap.Error(err))
+			response.WriteError(w, err)
+			return
+		}
+
+		redirect = &accepted
+	}
+
+	if _, err := h.idp.PerformLogout(r.Context(), sessionToken, nil); err != nil {
+		logger.Error("failed to perform native logout", zap.Error(err))
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, redirect)
+}
+
+func (h *Handler) RejectLogoutChallenge(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logoutChallenge := r.URL.Query().Get("logout_challenge")
+
+	if err := h.oauth2.RejectLogoutChallenge(r.Context(), logoutChallenge); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	response.WriteData(w, http.StatusOK, nil)
+}
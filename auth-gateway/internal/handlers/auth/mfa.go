@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+)
+
+// SubmitLoginTOTP completes an AAL2 login flow with the code of the
+// authenticator app and accepts the login challenge.
+func (h *Handler) SubmitLoginTOTP(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var form model.SubmitLoginSecondFactorForm
+
+	h.submitLogin(w, r, &form, &form.RememberChoice, func(ctx context.Context, flowID string, cookies []*http.Cookie) (model.SubmitLoginResponse, []*http.Cookie, error) {
+		return h.idp.SubmitLoginTOTP(ctx, flowID, cookies, &form)
+	})
+}
+
+// LinkSettingsTOTP sets up the authenticator app of the settings flow
+func (h *Handler) LinkSettingsTOTP(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.LinkSettingsTOTPForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	flow, outCookies, err := h.idp.LinkSettingsTOTP(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		h.writeSettingsError(w, r, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// UnlinkSettingsTOTP removes the authenticator app
+func (h *Handler) UnlinkSettingsTOTP(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.UnlinkSettingsTOTPForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	flow, outCookies, err := h.idp.UnlinkSettingsTOTP(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		h.writeSettingsError(w, r, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// SubmitLoginLookupSecret completes an AAL2 login flow with a backup code
+// instead of the authenticator app and accepts the login challenge.
+func (h *Handler) SubmitLoginLookupSecret(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var form model.SubmitLoginSecondFactorForm
+
+	h.submitLogin(w, r, &form, &form.RememberChoice, func(ctx context.Context, flowID string, cookies []*http.Cookie) (model.SubmitLoginResponse, []*http.Cookie, error) {
+		return h.idp.SubmitLoginLookupSecret(ctx, flowID, cookies, &form)
+	})
+}
+
+// lookupSecretUpdate is one of the backup code operations of IDPService.
+type lookupSecretUpdate func(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.UpdateSettingsLookupSecretForm) (model.SettingsFlow, []*http.Cookie, error)
+
+// updateSettingsLookupSecret serves one backup code operation, the codes
+// are on the returned flow when it shows them.
+func (h *Handler) updateSettingsLookupSecret(update lookupSecretUpdate) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		id := r.URL.Query().Get("id")
+
+		body, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		r.Body.Close()
+		var form model.UpdateSettingsLookupSecretForm
+
+		if err := json.Unmarshal(body, &form); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		flow, outCookies, err := update(r.Context(), id, r.Cookies(), &form)
+
+		if err != nil {
+			h.writeSettingsError(w, r, err)
+			return
+		}
+
+		util.ForwardSetCookieHeader(outCookies, w)
+		response.WriteData(w, http.StatusOK, flow)
+	}
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/service"
+	"github.com/julienschmidt/httprouter"
+)
+
+// native serves a handler for apps without a cookie jar. The IDP service
+// uses Kratos API flows and the session token the app sends in the
+// X-Session-Token header or as a bearer token.
+func native(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		ctx := service.WithNativeFlow(r.Context(), sessionTokenFrom(r))
+
+		// Cookies of an embedded browser must not leak into API flows.
+		r = r.WithContext(ctx)
+		r.Header.Del("Cookie")
+
+		handle(w, r, ps)
+	}
+}
+
+func sessionTokenFrom(r *http.Request) string {
+	if token := r.Header.Get("X-Session-Token"); token != "" {
+		return token
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+
+	return ""
+}
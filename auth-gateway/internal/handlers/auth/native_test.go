@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/service"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestNative(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    http.Header
+		wantToken string
+	}{
+		{
+			name:      "session token header",
+			header:    http.Header{"X-Session-Token": {"token"}},
+			wantToken: "token",
+		},
+		{
+			name:      "bearer token",
+			header:    http.Header{"Authorization": {"Bearer token"}},
+			wantToken: "token",
+		},
+		{
+			name: "session token header before bearer token",
+			header: http.Header{
+				"X-Session-Token": {"token"},
+				"Authorization":   {"Bearer other"},
+			},
+			wantToken: "token",
+		},
+		{
+			name:   "basic authorization",
+			header: http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}},
+		},
+		{
+			name: "no session token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/native/login/flows/email", nil)
+			r.Header = tt.header.Clone()
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+			r.AddCookie(&http.Cookie{Name: "ory_kratos_session", Value: "browser-session"})
+
+			called := false
+			handle := native(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				called = true
+
+				if cookies := r.Cookies(); len(cookies) != 0 {
+					t.Errorf("cookies = %v, want none", cookies)
+				}
+
+				if got := sessionTokenFrom(r); got != tt.wantToken {
+					t.Errorf("sessionTokenFrom() = %q, want %q", got, tt.wantToken)
+				}
+			})
+
+			handle(httptest.NewRecorder(), r, nil)
+
+			if !called {
+				t.Fatal("native() did not call the handler")
+			}
+		})
+	}
+}
+
+// logoutIDP records the native logout, the other IDPService methods are not
+// used by the logout handlers.
+type logoutIDP struct {
+	service.IDPService
+	token   string
+	cookies []*http.Cookie
+}
+
+func (f *logoutIDP) PerformLogout(ctx context.Context, token string, cookies []*http.Cookie) ([]*http.Cookie, error) {
+	f.token = token
+	f.cookies = cookies
+	return nil, nil
+}
+
+type logoutOAuth2 struct {
+	service.OAuth2Service
+	challenge string
+}
+
+func (f *logoutOAuth2) AcceptLogoutChallenge(ctx context.Context, challenge string) (model.AcceptOAuth2LogoutChallengeResponse, []*http.Cookie, error) {
+	f.challenge = challenge
+	return model.AcceptOAuth2LogoutChallengeResponse{RedirectTo: "http://hydra/oauth2/sessions/logout"}, nil, nil
+}
+
+func TestNativeLogout(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        string
+		header        http.Header
+		wantStatus    int
+		wantToken     string
+		wantChallenge string
+		wantRedirect  string
+	}{
+		{
+			name:       "session token only",
+			target:     "/native/logout",
+			header:     http.Header{"X-Session-Token": {"token"}},
+			wantStatus: http.StatusOK,
+			wantToken:  "token",
+		},
+		{
+			name:          "with logout challenge",
+			target:        "/native/logout?logout_challenge=challenge",
+			header:        http.Header{"Authorization": {"Bearer token"}},
+			wantStatus:    http.StatusOK,
+			wantToken:     "token",
+			wantChallenge: "challenge",
+			wantRedirect:  "http://hydra/oauth2/sessions/logout",
+		},
+		{
+			name:       "no session token",
+			target:     "/native/logout?logout_challenge=challenge",
+			header:     http.Header{},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := &logoutIDP{}
+			oauth2 := &logoutOAuth2{}
+			h := NewHandler(idp, oauth2, nil, model.AALPolicy{}, "")
+
+			r := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			r.Header = tt.header
+			r.AddCookie(&http.Cookie{Name: "ory_kratos_session", Value: "browser-session"})
+			w := httptest.NewRecorder()
+
+			native(h.NativeLogout)(w, r, nil)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if idp.token != tt.wantToken {
+				t.Errorf("logged out token = %q, want %q", idp.token, tt.wantToken)
+			}
+
+			if len(idp.cookies) != 0 {
+				t.Errorf("logout cookies = %v, want none", idp.cookies)
+			}
+
+			if oauth2.challenge != tt.wantChallenge {
+				t.Errorf("accepted challenge = %q, want %q", oauth2.challenge, tt.wantChallenge)
+			}
+
+			var body struct {
+				Data *model.AcceptOAuth2LogoutChallengeResponse `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+
+			if body.Data != nil && body.Data.RedirectTo != tt.wantRedirect || body.Data == nil && tt.wantRedirect != "" {
+				t.Errorf("body data = %+v, want redirect_to %q", body.Data, tt.wantRedirect)
+			}
+		})
+	}
+}
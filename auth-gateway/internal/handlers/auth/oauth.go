@@ -1,15 +1,68 @@
 package auth
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
+	"strings"
 
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
 	"github.com/julienschmidt/httprouter"
 )
 
-// CreateOAuth2Flow creates a new OAuth2 login flow in Hydra
+// CreateOAuth2Flow validates the authorization request and creates a new
+// OAuth2 login flow in Hydra
 func (h *Handler) CreateOAuth2Flow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if err := h.oauth2.ValidateAuthorizationRequest(r.Context(), r.URL.Query()); err != nil {
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			response.WriteErrorPage(w, err)
+		} else {
+			response.WriteError(w, err)
+		}
+
+		return
+	}
+
 	oauth2Url := h.oauth2.GetOAuth2URL(r.URL.Query())
 
 	// Return Redirect to Hydra
 	http.Redirect(w, r, oauth2Url, http.StatusFound)
 }
+
+// VerifyDeviceCode accepts the user code shown on the device. It does not
+// sign the user in itself: the returned redirect leads back to Hydra, which
+// answers with a login challenge on urls.login like for any authorization
+// request. The UI then runs the usual Kratos code login of CreateLoginFlow
+// and the consent before Hydra authorizes the device. This relies on
+// urls.login and urls.consent of Hydra pointing at the UI.
+func (h *Handler) VerifyDeviceCode(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	deviceChallenge := r.URL.Query().Get("device_challenge")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.AcceptOAuth2DeviceChallengeForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	form.Challenge = deviceChallenge
+	redirect, outCookies, err := h.oauth2.AcceptDeviceChallenge(r.Context(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, redirect)
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// oidcReturnTo returns the page Kratos sends the browser to after a social
+// sign-in, with the login challenge the UI completes the login for. It is
+// empty when no return URL is configured.
+func (h *Handler) oidcReturnTo(loginChallenge string) string {
+	if h.oidcReturnURL == "" || loginChallenge == "" {
+		return ""
+	}
+
+	returnURL, err := url.Parse(h.oidcReturnURL)
+	if err != nil {
+		return ""
+	}
+
+	query := returnURL.Query()
+	query.Set("login_challenge", loginChallenge)
+	returnURL.RawQuery = query.Encode()
+
+	return returnURL.String()
+}
+
+// StartLoginOIDC starts a social sign-in on the login flow and returns the
+// provider URL to send the browser to
+func (h *Handler) StartLoginOIDC(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.StartLoginOIDCForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	redirect, outCookies, err := h.idp.StartLoginOIDC(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, redirect)
+}
+
+// CompleteLoginOIDC accepts the login challenge once the browser is back
+// from the provider with a Kratos session. The id is the login flow the
+// social sign-in was started on, see oidcLoginFresh.
+func (h *Handler) CompleteLoginOIDC(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+	loginChallenge := r.URL.Query().Get("login_challenge")
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	validationErrors := make(map[string]string)
+
+	if id == "" {
+		validationErrors["id"] = "required"
+	}
+
+	if loginChallenge == "" {
+		validationErrors["login_challenge"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		response.WriteError(w, response.NewValidation(validationErrors))
+		return
+	}
+
+	flow, flowCookies, err := h.idp.GetLoginFlow(r.Context(), id, r.Cookies())
+
+	if err != nil {
+		logger.Error("failed to get oidc login flow", zap.String("flow_id", id), zap.Error(err))
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(flowCookies, w)
+	cookies := util.MergeCookies(r.Cookies(), flowCookies)
+
+	session, outCookies, err := h.idp.ToSession(r.Context(), cookies)
+
+	if err != nil {
+		logger.Error("no session after oidc login", zap.Error(err))
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+
+	fresh, err := oidcLoginFresh(&flow, loginChallenge, &session)
+
+	if err != nil {
+		logger.Error("oidc login flow does not belong to the login challenge", zap.String("flow_id", id))
+		response.WriteError(w, err)
+		return
+	}
+
+	h.acceptLogin(w, r, loginChallenge, &authenticatedLogin{
+		session: session,
+		fresh:   fresh,
+		cookies: util.MergeCookies(cookies, outCookies),
+	})
+}
+
+// oidcLoginFresh checks that the login flow was created for the login
+// challenge and tells whether the session was authenticated on it. A
+// session from before the flow is not fresh, acceptLogin then applies
+// prompt and max_age to it like to any existing session.
+func oidcLoginFresh(flow *model.LoginFlow, loginChallenge string, session *model.Session) (bool, error) {
+	if flow.LoginChallenge == "" || flow.LoginChallenge != loginChallenge {
+		return false, response.ErrInvalidFlow
+	}
+
+	if flow.IssuedAt == nil {
+		return false, nil
+	}
+
+	return !session.AuthenticatedAt.Before(*flow.IssuedAt), nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+)
+
+func TestOIDCLoginFresh(t *testing.T) {
+	issuedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	flow := model.LoginFlow{LoginChallenge: "challenge", IssuedAt: &issuedAt}
+
+	tests := []struct {
+		name            string
+		flow            model.LoginFlow
+		loginChallenge  string
+		authenticatedAt time.Time
+		wantFresh       bool
+		wantErr         error
+	}{
+		{
+			name:            "signed in on the flow",
+			flow:            flow,
+			loginChallenge:  "challenge",
+			authenticatedAt: issuedAt.Add(time.Minute),
+			wantFresh:       true,
+		},
+		{
+			name:            "session from before the flow",
+			flow:            flow,
+			loginChallenge:  "challenge",
+			authenticatedAt: issuedAt.Add(-time.Hour),
+			wantFresh:       false,
+		},
+		{
+			name:            "flow of another challenge",
+			flow:            flow,
+			loginChallenge:  "other",
+			authenticatedAt: issuedAt.Add(time.Minute),
+			wantErr:         response.ErrInvalidFlow,
+		},
+		{
+			name:            "flow without challenge",
+			flow:            model.LoginFlow{IssuedAt: &issuedAt},
+			loginChallenge:  "challenge",
+			authenticatedAt: issuedAt.Add(time.Minute),
+			wantErr:         response.ErrInvalidFlow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := model.Session{AuthenticatedAt: tt.authenticatedAt}
+			fresh, err := oidcLoginFresh(&tt.flow, tt.loginChallenge, &session)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("oidcLoginFresh() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if fresh != tt.wantFresh {
+				t.Errorf("oidcLoginFresh() fresh = %v, want %v", fresh, tt.wantFresh)
+			}
+		})
+	}
+}
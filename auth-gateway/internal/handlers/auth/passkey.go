@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+)
+
+// SubmitLoginPasskey signs the user in with the credential created for the
+// passkey options of the login flow and accepts the login challenge, see
+// acceptLogin.
+func (h *Handler) SubmitLoginPasskey(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var form model.SubmitLoginPasskeyForm
+
+	h.submitLogin(w, r, &form, &form.RememberChoice, func(ctx context.Context, flowID string, cookies []*http.Cookie) (model.SubmitLoginResponse, []*http.Cookie, error) {
+		return h.idp.SubmitLoginPasskey(ctx, flowID, cookies, &form)
+	})
+}
+
+// RegisterSettingsPasskey adds the passkey created for the options of the
+// settings flow
+func (h *Handler) RegisterSettingsPasskey(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.RegisterSettingsPasskeyForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	flow, outCookies, err := h.idp.RegisterSettingsPasskey(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		h.writeSettingsError(w, r, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// RemoveSettingsPasskey removes a registered passkey
+func (h *Handler) RemoveSettingsPasskey(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.RemoveSettingsPasskeyForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	flow, outCookies, err := h.idp.RemoveSettingsPasskey(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		h.writeSettingsError(w, r, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+)
+
+// CreateRecoveryFlow creates a new recovery flow in Kratos
+func (h *Handler) CreateRecoveryFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	flow, outCookies, err := h.idp.CreateRecoveryFlow(r.Context())
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// GetRecoveryFlow gets a recovery flow from Kratos
+func (h *Handler) GetRecoveryFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	flow, outCookies, err := h.idp.GetRecoveryFlow(r.Context(), id, r.Cookies())
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+func (h *Handler) SendRecoveryCode(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.SendRecoveryCodeForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	flow, outCookies, err := h.idp.SendRecoveryCode(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// SubmitRecoveryCode signs the user in with the recovery code and returns
+// the settings flow to continue with.
+func (h *Handler) SubmitRecoveryCode(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.SubmitRecoveryCodeForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	submitRes, outCookies, err := h.idp.SubmitRecoveryCode(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, submitRes)
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// CreateRegistrationFlow creates a new registration flow in Kratos for the
+// login challenge. A user who is already signed in is logged in right away
+// and prompt=none is rejected with login_required, like in CreateLoginFlow.
+func (h *Handler) CreateRegistrationFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	loginChallenge := r.URL.Query().Get("challenge")
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	loginRequest, err := h.oauth2.GetLoginRequest(r.Context(), loginChallenge)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	if accept, _ := h.knownUserLogin(w, r, &loginRequest); accept != nil {
+		redirect, outCookies, err := h.oauth2.AcceptOAuth2LoginChallenge(r.Context(), accept)
+
+		if err != nil {
+			logger.Error("failed to accept oauth2 login challenge", zap.Error(err))
+			response.WriteError(w, err)
+			return
+		}
+
+		util.ForwardSetCookieHeader(outCookies, w)
+		response.WriteData(w, http.StatusOK, model.RegistrationFlow{RedirectTo: redirect.RedirectTo})
+		return
+	}
+
+	// Signing up needs UI, which prompt=none rules out.
+	if loginRequest.OIDC.HasPrompt("none") {
+		if redirectTo, ok := h.rejectLoginRequired(w, r, loginChallenge); ok {
+			response.WriteData(w, http.StatusOK, model.RegistrationFlow{RedirectTo: redirectTo})
+		}
+
+		return
+	}
+
+	flow, outCookies, err := h.idp.CreateRegistrationFlow(r.Context(), loginChallenge)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// GetRegistrationFlow gets a registration flow from Kratos
+func (h *Handler) GetRegistrationFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	flow, outCookies, err := h.idp.GetRegistrationFlow(r.Context(), id, r.Cookies())
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+func (h *Handler) SendRegistrationCode(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.SendRegistrationCodeForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	flow, outCookies, err := h.idp.SendRegistrationCode(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// SubmitRegistrationCode creates the identity and accepts the login
+// challenge for the new user, see acceptLogin.
+func (h *Handler) SubmitRegistrationCode(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+	loginChallenge := r.URL.Query().Get("login_challenge")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.SubmitRegistrationCodeForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	submitRes, outCookies, err := h.idp.SubmitRegistrationCode(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+
+	h.acceptLogin(w, r, loginChallenge, &authenticatedLogin{
+		session:      submitRes.Session,
+		fresh:        true,
+		sessionToken: submitRes.SessionToken,
+		cookies:      util.MergeCookies(r.Cookies(), outCookies),
+		remember:     form.Remember,
+	})
+}
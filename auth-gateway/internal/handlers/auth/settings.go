@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// CreateSettingsFlow creates a new settings flow for the signed in user
+func (h *Handler) CreateSettingsFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	flow, outCookies, err := h.idp.CreateSettingsFlow(r.Context(), r.Cookies())
+
+	if err != nil {
+		h.writeSettingsError(w, r, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// GetSettingsFlow gets a settings flow from Kratos
+func (h *Handler) GetSettingsFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	flow, outCookies, err := h.idp.GetSettingsFlow(r.Context(), id, r.Cookies())
+
+	if err != nil {
+		h.writeSettingsError(w, r, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// UpdateSettingsProfile changes the identity traits, see
+// writeSettingsError for sessions that are too old for the change.
+func (h *Handler) UpdateSettingsProfile(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.URL.Query().Get("id")
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	r.Body.Close()
+	var form model.UpdateSettingsProfileForm
+
+	if err := json.Unmarshal(body, &form); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	flow, outCookies, err := h.idp.UpdateSettingsProfile(r.Context(), id, r.Cookies(), &form)
+
+	if err != nil {
+		h.writeSettingsError(w, r, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(outCookies, w)
+	response.WriteData(w, http.StatusOK, flow)
+}
+
+// writeSettingsError writes the error of a settings flow. When the session
+// is too old for the change or lacks the second factor Kratos asks for, the
+// error carries a refresh or aal2 login flow; the user signs in on it and
+// then retries.
+func (h *Handler) writeSettingsError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	var (
+		sentinel response.HTTPError
+		flowForm model.CreateLoginFlowForm
+	)
+
+	switch {
+	case errors.Is(err, response.ErrSessionRefreshRequired):
+		sentinel = response.ErrSessionRefreshRequired
+		flowForm.Refresh = true
+	case errors.Is(err, response.ErrAAL2Required):
+		sentinel = response.ErrAAL2Required
+		flowForm.AAL = model.AAL2
+	default:
+		response.WriteError(w, err)
+		return
+	}
+
+	loginFlow, loginCookies, err := h.idp.CreateLoginFlow(r.Context(), "", r.Cookies(), &flowForm)
+
+	if err != nil {
+		logger.Error("failed to create login flow for settings", zap.Error(err))
+		response.WriteError(w, err)
+		return
+	}
+
+	util.ForwardSetCookieHeader(loginCookies, w)
+	response.WriteError(w, response.WithDetails(sentinel, loginFlow))
+}
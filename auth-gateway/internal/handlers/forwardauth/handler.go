@@ -0,0 +1,108 @@
+package forwardauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/cache"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/service"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// maxCachedTokens bounds the memory used by the introspection cache.
+const maxCachedTokens = 10_000
+
+type Handler struct {
+	oauth2 service.OAuth2Service
+	tokens *cache.TTL[string, model.IntrospectedToken]
+	// cacheTTL caps how long an introspection result is reused.
+	cacheTTL time.Duration
+}
+
+func NewHandler(oauth2 service.OAuth2Service, cacheTTL time.Duration) *Handler {
+	return &Handler{
+		oauth2:   oauth2,
+		tokens:   cache.NewTTL[string, model.IntrospectedToken](maxCachedTokens),
+		cacheTTL: cacheTTL,
+	}
+}
+
+func (h *Handler) RegisterRoutes(r *httprouter.Router) {
+	r.GET("/forward-auth", h.ForwardAuth)
+}
+
+// ForwardAuth is the Traefik forward-auth endpoint. It introspects the
+// bearer token and answers 200 with the identity headers, or 401.
+func (h *Handler) ForwardAuth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := middleware.GetLoggerFrom(r.Context())
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+
+	if !ok || token == "" {
+		unauthorized(w, "")
+		return
+	}
+
+	key := tokenKey(token)
+	introspected, cached := h.tokens.Get(key)
+
+	if !cached {
+		var err error
+		introspected, err = h.oauth2.IntrospectToken(r.Context(), token)
+
+		if err != nil {
+			logger.Error("failed to introspect token", zap.Error(err))
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		if introspected.Active {
+			h.tokens.Set(key, introspected, cacheUntil(introspected.ExpiresAt, time.Now(), h.cacheTTL))
+		}
+	}
+
+	if !introspected.Active || introspected.TokenUse != "access_token" {
+		unauthorized(w, "invalid_token")
+		return
+	}
+
+	w.Header().Set("X-User-Id", introspected.Subject)
+	w.Header().Set("X-Client-Id", introspected.ClientID)
+	w.Header().Set("X-Scopes", introspected.Scope)
+	w.WriteHeader(http.StatusOK)
+}
+
+// cacheUntil returns when a cached introspection result expires, at the
+// token expiry but no later than maxTTL from now. Revoked tokens are only
+// noticed on the next introspection.
+func cacheUntil(expiresAt, now time.Time, maxTTL time.Duration) time.Time {
+	if limit := now.Add(maxTTL); limit.Before(expiresAt) {
+		return limit
+	}
+
+	return expiresAt
+}
+
+// tokenKey hashes the token so raw tokens are never kept in memory longer
+// than the request.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func unauthorized(w http.ResponseWriter, errorCode string) {
+	challenge := `Bearer realm="auth-gateway"`
+	if errorCode != "" {
+		challenge += `, error="` + errorCode + `"`
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.WriteHeader(http.StatusUnauthorized)
+}
@@ -0,0 +1,44 @@
+package forwardauth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheUntil(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		maxTTL    time.Duration
+		want      time.Time
+	}{
+		{
+			name:      "token outlives the cache",
+			expiresAt: now.Add(time.Hour),
+			maxTTL:    30 * time.Second,
+			want:      now.Add(30 * time.Second),
+		},
+		{
+			name:      "token expires first",
+			expiresAt: now.Add(10 * time.Second),
+			maxTTL:    30 * time.Second,
+			want:      now.Add(10 * time.Second),
+		},
+		{
+			name:      "cache disabled",
+			expiresAt: now.Add(time.Hour),
+			maxTTL:    0,
+			want:      now,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheUntil(tt.expiresAt, now, tt.maxTTL); !got.Equal(tt.want) {
+				t.Errorf("cacheUntil() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,111 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// FlowType is the Kratos flow an email code belongs to.
+type FlowType string
+
+const (
+	FlowTypeLogin        FlowType = "login"
+	FlowTypeRegistration FlowType = "registration"
+)
+
 type LoginFlow struct {
-	ID         string `json:"id"`
+	ID         string `json:"id,omitempty"`
 	CsrfToken  string `json:"csrf_token,omitempty"`
 	Identifier string `json:"identifier,omitempty"`
+	// Type is registration when the email has no account yet and the code
+	// was sent for a registration flow instead.
+	Type FlowType `json:"type,omitempty"`
+	// RedirectTo is set instead of a flow when the login challenge was
+	// accepted without asking the user to authenticate again.
+	RedirectTo string `json:"redirect_to,omitempty"`
+	// OIDC holds what the relying party asked for, e.g. ui_locales and
+	// acr_values, so the UI can adapt.
+	OIDC *OIDCContext `json:"oidc,omitempty"`
+	// Methods are the authentication methods the flow offers, e.g. code
+	// for the first factor or totp for an AAL2 flow.
+	Methods []string `json:"methods,omitempty"`
+	// PasskeyOptions are the options for navigator.credentials.get() when
+	// the flow offers passkeys.
+	PasskeyOptions json.RawMessage `json:"passkey_options,omitempty"`
+	// Providers are the social sign-in providers the flow offers.
+	Providers []string `json:"providers,omitempty"`
+	// SessionToken is set on the AAL2 flow of a native login, the app
+	// submits the second factor with the session of the first one.
+	SessionToken string `json:"session_token,omitempty"`
+	// LoginChallenge is the Hydra login challenge of a browser flow.
+	LoginChallenge string     `json:"login_challenge,omitempty"`
+	IssuedAt       *time.Time `json:"issued_at,omitempty"`
+	FlowUI
+}
+
+type CreateLoginFlowForm struct {
+	// Refresh forces the user to authenticate again even with a valid
+	// session.
+	Refresh bool `json:"refresh"`
+	// AAL requests a second factor for the current session when set to
+	// aal2.
+	AAL string `json:"aal,omitempty"`
+	// ReturnTo is where Kratos sends the browser after a login that left
+	// the page, e.g. a social sign-in.
+	ReturnTo string `json:"return_to,omitempty"`
 }
 
 type Session struct {
-	ID string `json:"id"`
+	ID              string    `json:"id"`
+	IdentityID      string    `json:"identity_id"`
+	AuthenticatedAt time.Time `json:"authenticated_at"`
+	AAL             string    `json:"aal,omitempty"`
+	Methods         []string  `json:"methods,omitempty"`
+}
+
+// ACR returns the authentication context class reference for the session,
+// which is the Kratos assurance level (aal1, aal2, ...).
+func (s Session) ACR() string {
+	return s.AAL
+}
+
+// Satisfies reports whether the session has at least the assurance level.
+func (s Session) Satisfies(aal string) bool {
+	return aal != AAL2 || s.AAL == AAL2
+}
+
+// AMR maps the Kratos authentication methods to RFC 8176 values.
+func (s Session) AMR() []string {
+	var amr []string
+	seen := make(map[string]bool)
+
+	for _, method := range s.Methods {
+		var value string
+
+		switch method {
+		case "password":
+			value = "pwd"
+		case "code", "totp", "lookup_secret":
+			value = "otp"
+		case "webauthn", "passkey":
+			value = "hwk"
+		case "oidc", "saml":
+			value = "fed"
+		default:
+			continue
+		}
+
+		if !seen[value] {
+			seen[value] = true
+			amr = append(amr, value)
+		}
+	}
+
+	if s.AAL == AAL2 && !seen["mfa"] {
+		amr = append(amr, "mfa")
+	}
+
+	return amr
 }
 
 type SendLoginEmailCodeForm struct {
@@ -15,21 +113,66 @@ type SendLoginEmailCodeForm struct {
 	CsrfToken  string `json:"csrf_token"`
 }
 
+// RememberChoice is part of every form that signs the user in.
+type RememberChoice struct {
+	// Remember is the user's "remember me" choice, nil falls back to the
+	// configured default.
+	Remember *bool `json:"remember,omitempty"`
+}
+
 type SubmitLoginEmailCodeForm struct {
 	Identifier string `json:"identifier"`
 	Code       string `json:"code"`
 	CsrfToken  string `json:"csrf_token"`
+	// Type is the flow type returned when the code was sent, empty means
+	// login.
+	Type FlowType `json:"type,omitempty"`
+	RememberChoice
 }
 
-type SubmitLoginEmailCodeResponse struct {
+// SubmitLoginResponse is the session a submitted login flow signed in,
+// whatever the method.
+type SubmitLoginResponse struct {
 	Session Session `json:"session"`
+	// SessionToken is only set for native flows, the app sends it instead
+	// of a session cookie.
+	SessionToken string `json:"session_token,omitempty"`
+}
+
+type LogoutFlow struct {
+	LogoutToken string `json:"logout_token"`
+	LogoutURL   string `json:"logout_url"`
 }
 
 type AcceptOAuth2LoginChallengeForm struct {
 	Challenge string `json:"challenge"`
 	Subject   string `json:"subject"`
+	// SessionID is the identity provider session, forwarded to Hydra as
+	// the login context.
+	SessionID string `json:"session_id,omitempty"`
+	// Remember is nil when the user made no choice and the configured
+	// default applies.
+	Remember *bool    `json:"remember,omitempty"`
+	ACR      string   `json:"acr,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+	// ExtendSessionLifespan may only be requested when Hydra skipped the
+	// login, i.e. the user already has an authentication session.
+	ExtendSessionLifespan bool `json:"extend_session_lifespan,omitempty"`
 }
 
 type AcceptOAuth2LoginChallengeResponse struct {
 	RedirectTo string `json:"redirect_to"`
+	// SessionToken is set by the native login and registration endpoints
+	// so the app gets its new session along with the redirect.
+	SessionToken string `json:"session_token,omitempty"`
+}
+
+type RejectOAuth2LoginChallengeForm struct {
+	Challenge        string            `json:"challenge"`
+	Error            OAuth2ErrorReason `json:"error"`
+	ErrorDescription string            `json:"error_description"`
+}
+
+type RejectOAuth2LoginChallengeResponse struct {
+	RedirectTo string `json:"redirect_to"`
 }
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSessionSatisfies(t *testing.T) {
+	tests := []struct {
+		name       string
+		sessionAAL string
+		required   string
+		want       bool
+	}{
+		{name: "aal1 session for aal1", sessionAAL: AAL1, required: AAL1, want: true},
+		{name: "aal1 session for aal2", sessionAAL: AAL1, required: AAL2, want: false},
+		{name: "aal2 session for aal1", sessionAAL: AAL2, required: AAL1, want: true},
+		{name: "aal2 session for aal2", sessionAAL: AAL2, required: AAL2, want: true},
+		{name: "unknown session for aal2", sessionAAL: "", required: AAL2, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := Session{AAL: tt.sessionAAL}
+
+			if got := session.Satisfies(tt.required); got != tt.want {
+				t.Errorf("Satisfies(%q) = %v, want %v", tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionAMR(t *testing.T) {
+	tests := []struct {
+		name    string
+		session Session
+		want    []string
+	}{
+		{name: "code", session: Session{AAL: AAL1, Methods: []string{"code"}}, want: []string{"otp"}},
+		{name: "code and totp", session: Session{AAL: AAL2, Methods: []string{"code", "totp"}}, want: []string{"otp", "mfa"}},
+		{name: "passkey", session: Session{AAL: AAL1, Methods: []string{"passkey"}}, want: []string{"hwk"}},
+		{name: "social and backup code", session: Session{AAL: AAL2, Methods: []string{"oidc", "lookup_secret"}}, want: []string{"fed", "otp", "mfa"}},
+		{name: "unknown method", session: Session{AAL: AAL1, Methods: []string{"magic"}}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.session.AMR(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AMR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+type Client struct {
+	ID                      string     `json:"client_id"`
+	Name                    string     `json:"client_name,omitempty"`
+	Secret                  string     `json:"client_secret,omitempty"`
+	RedirectURIs            []string   `json:"redirect_uris"`
+	PostLogoutRedirectURIs  []string   `json:"post_logout_redirect_uris,omitempty"`
+	GrantTypes              []string   `json:"grant_types"`
+	ResponseTypes           []string   `json:"response_types"`
+	Scope                   string     `json:"scope,omitempty"`
+	Audience                []string   `json:"audience,omitempty"`
+	TokenEndpointAuthMethod string     `json:"token_endpoint_auth_method,omitempty"`
+	SkipConsent             bool       `json:"skip_consent"`
+	CreatedAt               *time.Time `json:"created_at,omitempty"`
+	UpdatedAt               *time.Time `json:"updated_at,omitempty"`
+}
+
+type ClientList struct {
+	Clients       []Client `json:"clients"`
+	NextPageToken string   `json:"next_page_token,omitempty"`
+}
+
+type ListClientsForm struct {
+	PageSize  int64  `json:"page_size"`
+	PageToken string `json:"page_token"`
+}
+
+// ClientForm is used to create and replace clients. The secret is always
+// generated by Hydra and only returned once, on create.
+type ClientForm struct {
+	Name                    string   `json:"client_name"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	Scope                   string   `json:"scope"`
+	Audience                []string `json:"audience"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	SkipConsent             bool     `json:"skip_consent"`
+}
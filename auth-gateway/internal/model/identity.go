@@ -0,0 +1,15 @@
+package model
+
+type Identity struct {
+	ID                  string              `json:"id"`
+	SchemaID            string              `json:"schema_id"`
+	Traits              map[string]any      `json:"traits"`
+	MetadataPublic      map[string]any      `json:"metadata_public,omitempty"`
+	VerifiableAddresses []VerifiableAddress `json:"verifiable_addresses,omitempty"`
+}
+
+type VerifiableAddress struct {
+	Value    string `json:"value"`
+	Via      string `json:"via"`
+	Verified bool   `json:"verified"`
+}
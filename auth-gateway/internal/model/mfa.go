@@ -0,0 +1,89 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Authenticator assurance levels of a Kratos session.
+const (
+	AAL1 = "aal1"
+	AAL2 = "aal2"
+)
+
+// AALPolicy decides which logins need a second factor before the login
+// challenge is accepted.
+type AALPolicy struct {
+	// Clients always require AAL2, e.g. admin consoles.
+	Clients []string
+	// ACRValues require AAL2 when the client asks for one of them in
+	// acr_values.
+	ACRValues []string
+}
+
+// RequiredAAL returns the assurance level the login request needs.
+func (p AALPolicy) RequiredAAL(request *LoginRequest) string {
+	if slices.Contains(p.Clients, request.Client.ID) {
+		return AAL2
+	}
+
+	for _, acr := range request.OIDC.ACRValues {
+		if slices.Contains(p.ACRValues, acr) {
+			return AAL2
+		}
+	}
+
+	return AAL1
+}
+
+// TOTPSettings is the authenticator app part of a settings flow.
+type TOTPSettings struct {
+	// Linked is true when an authenticator app is set up.
+	Linked bool `json:"linked"`
+	// QRCode is a data URI of the QR code to scan, only set when no app
+	// is linked yet.
+	QRCode string `json:"qr_code,omitempty"`
+	// Secret is the key behind the QR code for manual entry.
+	Secret string `json:"secret,omitempty"`
+}
+
+type LinkSettingsTOTPForm struct {
+	// Code is the current code of the authenticator app, it proves the
+	// app was set up correctly.
+	Code      string `json:"code"`
+	CsrfToken string `json:"csrf_token"`
+}
+
+type UnlinkSettingsTOTPForm struct {
+	CsrfToken string `json:"csrf_token"`
+}
+
+// LookupSecretSettings is the backup code part of a settings flow.
+type LookupSecretSettings struct {
+	// Enabled is true when the identity has confirmed backup codes.
+	Enabled bool `json:"enabled"`
+	// ConfirmRequired is true after new codes were generated, they only
+	// replace the old ones once confirmed.
+	ConfirmRequired bool `json:"confirm_required"`
+	// Codes are only set in the response that generated or revealed
+	// them, they can be revealed once per settings flow.
+	Codes []LookupSecretCode `json:"codes,omitempty"`
+}
+
+type LookupSecretCode struct {
+	// Code is empty for a code that was already used.
+	Code   string     `json:"code,omitempty"`
+	UsedAt *time.Time `json:"used_at,omitempty"`
+}
+
+type UpdateSettingsLookupSecretForm struct {
+	CsrfToken string `json:"csrf_token"`
+}
+
+// SubmitLoginSecondFactorForm submits the code of the authenticator app or
+// a backup code on an AAL2 login flow.
+type SubmitLoginSecondFactorForm struct {
+	Code      string `json:"code"`
+	CsrfToken string `json:"csrf_token"`
+	RememberChoice
+}
@@ -0,0 +1,56 @@
+package model
+
+import "testing"
+
+func TestAALPolicyRequiredAAL(t *testing.T) {
+	policy := AALPolicy{
+		Clients:   []string{"admin-console"},
+		ACRValues: []string{"aal2"},
+	}
+
+	tests := []struct {
+		name    string
+		policy  AALPolicy
+		request LoginRequest
+		want    string
+	}{
+		{
+			name:    "empty policy",
+			policy:  AALPolicy{},
+			request: LoginRequest{Client: OAuth2Client{ID: "admin-console"}, OIDC: OIDCContext{ACRValues: []string{"aal2"}}},
+			want:    AAL1,
+		},
+		{
+			name:    "other client",
+			policy:  policy,
+			request: LoginRequest{Client: OAuth2Client{ID: "app"}},
+			want:    AAL1,
+		},
+		{
+			name:    "aal2 client",
+			policy:  policy,
+			request: LoginRequest{Client: OAuth2Client{ID: "admin-console"}},
+			want:    AAL2,
+		},
+		{
+			name:    "aal2 acr value",
+			policy:  policy,
+			request: LoginRequest{Client: OAuth2Client{ID: "app"}, OIDC: OIDCContext{ACRValues: []string{"aal1", "aal2"}}},
+			want:    AAL2,
+		},
+		{
+			name:    "other acr value",
+			policy:  policy,
+			request: LoginRequest{Client: OAuth2Client{ID: "app"}, OIDC: OIDCContext{ACRValues: []string{"aal1"}}},
+			want:    AAL1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.RequiredAAL(&tt.request); got != tt.want {
+				t.Errorf("RequiredAAL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// OAuth2ErrorReason is an OAuth2 / OpenID Connect error code that is sent
+// back to the relying party when a challenge is rejected.
+type OAuth2ErrorReason string
+
+const (
+	OAuth2ErrorAccessDenied             OAuth2ErrorReason = "access_denied"
+	OAuth2ErrorLoginRequired            OAuth2ErrorReason = "login_required"
+	OAuth2ErrorConsentRequired          OAuth2ErrorReason = "consent_required"
+	OAuth2ErrorInteractionRequired      OAuth2ErrorReason = "interaction_required"
+	OAuth2ErrorAccountSelectionRequired OAuth2ErrorReason = "account_selection_required"
+)
+
+// Valid reports whether the reason is one the gateway is allowed to send.
+func (r OAuth2ErrorReason) Valid() bool {
+	switch r {
+	case OAuth2ErrorAccessDenied,
+		OAuth2ErrorLoginRequired,
+		OAuth2ErrorConsentRequired,
+		OAuth2ErrorInteractionRequired,
+		OAuth2ErrorAccountSelectionRequired:
+		return true
+	}
+
+	return false
+}
+
+type OAuth2Client struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	LogoURI   string `json:"logo_uri,omitempty"`
+	ClientURI string `json:"client_uri,omitempty"`
+	PolicyURI string `json:"policy_uri,omitempty"`
+	TosURI    string `json:"tos_uri,omitempty"`
+}
+
+// OIDCContext carries the OpenID Connect parameters of the authorization
+// request.
+type OIDCContext struct {
+	Prompt    []string `json:"prompt,omitempty"`
+	MaxAge    *int64   `json:"max_age,omitempty"`
+	LoginHint string   `json:"login_hint,omitempty"`
+	UILocales []string `json:"ui_locales,omitempty"`
+	ACRValues []string `json:"acr_values,omitempty"`
+	Display   string   `json:"display,omitempty"`
+}
+
+func (c OIDCContext) HasPrompt(prompt string) bool {
+	return slices.Contains(c.Prompt, prompt)
+}
+
+// MaxAgeExceeded reports whether an authentication at authenticatedAt is
+// too old at now for the requested max_age.
+func (c OIDCContext) MaxAgeExceeded(authenticatedAt, now time.Time) bool {
+	if c.MaxAge == nil {
+		return false
+	}
+
+	return now.Sub(authenticatedAt) > time.Duration(*c.MaxAge)*time.Second
+}
+
+type LoginRequest struct {
+	Challenge      string       `json:"challenge"`
+	Subject        string       `json:"subject"`
+	Skip           bool         `json:"skip"`
+	SessionID      string       `json:"session_id"`
+	RequestedScope []string     `json:"requested_scope"`
+	Client         OAuth2Client `json:"client"`
+	OIDC           OIDCContext  `json:"oidc"`
+}
+
+type ConsentRequest struct {
+	Challenge         string       `json:"challenge"`
+	Subject           string       `json:"subject"`
+	Skip              bool         `json:"skip"`
+	RequestedScope    []string     `json:"requested_scope"`
+	RequestedAudience []string     `json:"requested_audience"`
+	Client            OAuth2Client `json:"client"`
+}
+
+type AcceptOAuth2ConsentChallengeForm struct {
+	Challenge     string   `json:"challenge"`
+	GrantScope    []string `json:"grant_scope"`
+	GrantAudience []string `json:"grant_audience"`
+	Remember      bool     `json:"remember"`
+	// Token claims are filled by the gateway from the identity and are
+	// never read from the request body.
+	IDTokenClaims     map[string]any `json:"-"`
+	AccessTokenClaims map[string]any `json:"-"`
+}
+
+type AcceptOAuth2ConsentChallengeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type RejectOAuth2ConsentChallengeForm struct {
+	Challenge        string            `json:"challenge"`
+	Error            OAuth2ErrorReason `json:"error"`
+	ErrorDescription string            `json:"error_description"`
+}
+
+type RejectOAuth2ConsentChallengeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type LogoutRequest struct {
+	Challenge   string       `json:"challenge"`
+	Subject     string       `json:"subject"`
+	SessionID   string       `json:"session_id"`
+	RPInitiated bool         `json:"rp_initiated"`
+	Client      OAuth2Client `json:"client"`
+}
+
+type AcceptOAuth2LogoutChallengeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type AcceptOAuth2DeviceChallengeForm struct {
+	Challenge string `json:"challenge"`
+	UserCode  string `json:"user_code"`
+}
+
+type AcceptOAuth2DeviceChallengeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type IntrospectedToken struct {
+	Active    bool           `json:"active"`
+	Subject   string         `json:"sub,omitempty"`
+	ClientID  string         `json:"client_id,omitempty"`
+	Scope     string         `json:"scope,omitempty"`
+	Audience  []string       `json:"aud,omitempty"`
+	TokenUse  string         `json:"token_use,omitempty"`
+	ExpiresAt time.Time      `json:"exp"`
+	Extra     map[string]any `json:"ext,omitempty"`
+}
+
+type ConsentSession struct {
+	Client          OAuth2Client `json:"client"`
+	GrantedScope    []string     `json:"granted_scope"`
+	GrantedAudience []string     `json:"granted_audience,omitempty"`
+	GrantedAt       *time.Time   `json:"granted_at,omitempty"`
+	Remember        bool         `json:"remember"`
+}
@@ -0,0 +1,15 @@
+package model
+
+type StartLoginOIDCForm struct {
+	// Provider is the ID of a social sign-in provider configured in Kratos,
+	// e.g. google or github.
+	Provider  string `json:"provider"`
+	CsrfToken string `json:"csrf_token"`
+}
+
+type StartLoginOIDCResponse struct {
+	// RedirectTo is the authorization URL of the provider. The provider
+	// sends the browser back to Kratos, which completes the flow and
+	// redirects to the login return URL.
+	RedirectTo string `json:"redirect_to"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PasskeySettings is the passkey part of a settings flow.
+type PasskeySettings struct {
+	// CreateOptions are the options for navigator.credentials.create() to
+	// register a new passkey.
+	CreateOptions json.RawMessage     `json:"create_options,omitempty"`
+	Credentials   []PasskeyCredential `json:"credentials"`
+}
+
+// PasskeyCredential is a registered passkey, the ID is used to remove it.
+type PasskeyCredential struct {
+	ID          string     `json:"id"`
+	DisplayName string     `json:"display_name,omitempty"`
+	AddedAt     *time.Time `json:"added_at,omitempty"`
+}
+
+type SubmitLoginPasskeyForm struct {
+	// Credential is the result of navigator.credentials.get() serialized
+	// as JSON, binary fields base64url encoded.
+	Credential json.RawMessage `json:"credential"`
+	CsrfToken  string          `json:"csrf_token"`
+	RememberChoice
+}
+
+type RegisterSettingsPasskeyForm struct {
+	// Credential is the result of navigator.credentials.create()
+	// serialized as JSON, binary fields base64url encoded.
+	Credential json.RawMessage `json:"credential"`
+	CsrfToken  string          `json:"csrf_token"`
+}
+
+type RemoveSettingsPasskeyForm struct {
+	ID        string `json:"id"`
+	CsrfToken string `json:"csrf_token"`
+}
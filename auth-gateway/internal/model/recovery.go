@@ -0,0 +1,25 @@
+package model
+
+type RecoveryFlow struct {
+	ID        string `json:"id"`
+	CsrfToken string `json:"csrf_token,omitempty"`
+	Email     string `json:"email,omitempty"`
+	FlowUI
+}
+
+type SendRecoveryCodeForm struct {
+	Email     string `json:"email"`
+	CsrfToken string `json:"csrf_token"`
+}
+
+type SubmitRecoveryCodeForm struct {
+	Code      string `json:"code"`
+	CsrfToken string `json:"csrf_token"`
+}
+
+// SubmitRecoveryCodeResponse points to the settings flow where the
+// recovered user can fix the account, e.g. change the email address.
+// The response also sets the session cookie.
+type SubmitRecoveryCodeResponse struct {
+	SettingsFlowID string `json:"settings_flow_id"`
+}
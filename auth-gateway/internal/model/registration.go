@@ -0,0 +1,32 @@
+package model
+
+type RegistrationFlow struct {
+	ID        string `json:"id,omitempty"`
+	CsrfToken string `json:"csrf_token,omitempty"`
+	// Traits holds the identity traits entered so far, shaped like the
+	// identity schema, e.g. {"email": "..."}.
+	Traits map[string]any `json:"traits,omitempty"`
+	// RedirectTo is set instead of a flow when the user already has a
+	// session and the login challenge was accepted.
+	RedirectTo string `json:"redirect_to,omitempty"`
+	FlowUI
+}
+
+type SendRegistrationCodeForm struct {
+	Traits    map[string]any `json:"traits"`
+	CsrfToken string         `json:"csrf_token"`
+}
+
+type SubmitRegistrationCodeForm struct {
+	Traits    map[string]any `json:"traits"`
+	Code      string         `json:"code"`
+	CsrfToken string         `json:"csrf_token"`
+	RememberChoice
+}
+
+type SubmitRegistrationCodeResponse struct {
+	Session Session `json:"session"`
+	// SessionToken is only set for native flows, the app sends it instead
+	// of a session cookie.
+	SessionToken string `json:"session_token,omitempty"`
+}
@@ -0,0 +1,24 @@
+package model
+
+type SettingsFlow struct {
+	ID        string `json:"id"`
+	CsrfToken string `json:"csrf_token,omitempty"`
+	// Traits are the current identity traits, shaped like the identity
+	// schema.
+	Traits map[string]any `json:"traits,omitempty"`
+	// VerificationFlowID is set after the email address changed, the new
+	// address has to be verified with the code sent to it.
+	VerificationFlowID string `json:"verification_flow_id,omitempty"`
+	// TOTP is nil when authenticator apps are not enabled.
+	TOTP *TOTPSettings `json:"totp,omitempty"`
+	// LookupSecret is nil when backup codes are not enabled.
+	LookupSecret *LookupSecretSettings `json:"lookup_secret,omitempty"`
+	// Passkeys is nil when passkeys are not enabled.
+	Passkeys *PasskeySettings `json:"passkeys,omitempty"`
+	FlowUI
+}
+
+type UpdateSettingsProfileForm struct {
+	Traits    map[string]any `json:"traits"`
+	CsrfToken string         `json:"csrf_token"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// UIMessage is a message Kratos attached to a flow or one of its fields,
+// e.g. "An email containing a code has been sent" or "The code is invalid".
+type UIMessage struct {
+	// ID is the stable Kratos message ID, e.g. 4010008, use it instead of
+	// the text to react to a message.
+	ID      int64          `json:"id"`
+	Type    string         `json:"type"`
+	Text    string         `json:"text"`
+	Context map[string]any `json:"context,omitempty"`
+}
+
+// FlowUI is what the UI needs to render any Kratos flow besides its own
+// fields. It is embedded in every flow model.
+type FlowUI struct {
+	State     string     `json:"state,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// RequestedAAL is set on login flows that ask for a second factor.
+	RequestedAAL string      `json:"requested_aal,omitempty"`
+	Messages     []UIMessage `json:"messages,omitempty"`
+	// FieldErrors holds the error messages of the fields, keyed by the
+	// field name, e.g. "code" or "traits.email".
+	FieldErrors map[string][]UIMessage `json:"field_errors,omitempty"`
+	// Client is the OAuth2 client the flow was started for.
+	Client *OAuth2Client `json:"client,omitempty"`
+}
+
+// HasErrors reports whether Kratos rejected the last submission.
+func (ui FlowUI) HasErrors() bool {
+	if len(ui.FieldErrors) > 0 {
+		return true
+	}
+
+	for _, message := range ui.Messages {
+		if message.Type == "error" {
+			return true
+		}
+	}
+
+	return false
+}
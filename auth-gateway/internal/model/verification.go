@@ -0,0 +1,20 @@
+package model
+
+type VerificationFlow struct {
+	ID        string `json:"id"`
+	CsrfToken string `json:"csrf_token,omitempty"`
+	Email     string `json:"email,omitempty"`
+	// Verified is true once the code was accepted.
+	Verified bool `json:"verified"`
+	FlowUI
+}
+
+type SendVerificationCodeForm struct {
+	Email     string `json:"email"`
+	CsrfToken string `json:"csrf_token"`
+}
+
+type SubmitVerificationCodeForm struct {
+	Code      string `json:"code"`
+	CsrfToken string `json:"csrf_token"`
+}
@@ -0,0 +1,94 @@
+package ory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	hydra "github.com/ory/hydra-client-go/v2"
+)
+
+// The device authorization grant was added in Hydra v2.3 and is not part of
+// hydra-client-go v2.2, so the admin endpoint is called directly.
+
+type acceptDeviceUserCodeRequest struct {
+	UserCode string `json:"user_code"`
+}
+
+// DeviceAPIError is returned when Hydra answers a device request with a
+// non-2xx status.
+type DeviceAPIError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *DeviceAPIError) Error() string {
+	return fmt.Sprintf("hydra device request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// OAuth2Error decodes the OAuth2 error Hydra sends as body, like the
+// generated client does for its own calls.
+func (e *DeviceAPIError) OAuth2Error() (hydra.ErrorOAuth2, bool) {
+	var oauth2Err hydra.ErrorOAuth2
+	if err := json.Unmarshal(e.Body, &oauth2Err); err != nil || oauth2Err.Error == nil {
+		return hydra.ErrorOAuth2{}, false
+	}
+
+	return oauth2Err, true
+}
+
+// AcceptDeviceUserCode accepts the device challenge with the user code the
+// user typed in and returns where to send the browser next.
+func AcceptDeviceUserCode(ctx context.Context, admin *hydra.APIClient, challenge string, userCode string) (*hydra.OAuth2RedirectTo, *http.Response, error) {
+	cfg := admin.GetConfig()
+	endpoint := url.URL{
+		Scheme:   cfg.Scheme,
+		Host:     cfg.Host,
+		Path:     "/admin/oauth2/auth/requests/device/accept",
+		RawQuery: url.Values{"device_challenge": {challenge}}.Encode(),
+	}
+
+	body, err := json.Marshal(acceptDeviceUserCodeRequest{UserCode: userCode})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, res, err
+	}
+
+	if res.StatusCode >= 300 {
+		return nil, res, &DeviceAPIError{StatusCode: res.StatusCode, Body: resBody}
+	}
+
+	var redirect hydra.OAuth2RedirectTo
+	if err := json.Unmarshal(resBody, &redirect); err != nil {
+		return nil, res, err
+	}
+
+	return &redirect, res, nil
+}
@@ -0,0 +1,95 @@
+package ory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptDeviceUserCode(t *testing.T) {
+	hydraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method = %s, want PUT", r.Method)
+		}
+
+		if r.URL.Path != "/admin/oauth2/auth/requests/device/accept" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+
+		if got := r.URL.Query().Get("device_challenge"); got != "challenge" {
+			t.Errorf("device_challenge = %q, want %q", got, "challenge")
+		}
+
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+
+		if len(body) != 1 || body["user_code"] != "ABCD-EFGH" {
+			t.Errorf("body = %v, want only the user code", body)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"redirect_to":"http://hydra/oauth2/device/verify?device_verifier=verifier"}`))
+	}))
+	defer hydraServer.Close()
+
+	admin, err := NewHydraAdmin(hydraServer.URL, hydraServer.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redirect, res, err := AcceptDeviceUserCode(context.Background(), admin, "challenge", "ABCD-EFGH")
+	if err != nil {
+		t.Fatalf("AcceptDeviceUserCode() error = %v", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", res.StatusCode)
+	}
+
+	if want := "http://hydra/oauth2/device/verify?device_verifier=verifier"; redirect.RedirectTo != want {
+		t.Errorf("redirect_to = %q, want %q", redirect.RedirectTo, want)
+	}
+}
+
+func TestAcceptDeviceUserCodeError(t *testing.T) {
+	hydraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_request","error_description":"The user code is invalid"}`))
+	}))
+	defer hydraServer.Close()
+
+	admin, err := NewHydraAdmin(hydraServer.URL, hydraServer.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, res, err := AcceptDeviceUserCode(context.Background(), admin, "challenge", "WRONG")
+
+	var deviceErr *DeviceAPIError
+	if !errors.As(err, &deviceErr) {
+		t.Fatalf("AcceptDeviceUserCode() error = %v, want DeviceAPIError", err)
+	}
+
+	if res == nil || res.StatusCode != http.StatusBadRequest || deviceErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %v, want 400", deviceErr.StatusCode)
+	}
+
+	oauth2Err, ok := deviceErr.OAuth2Error()
+	if !ok {
+		t.Fatal("OAuth2Error() ok = false, want true")
+	}
+
+	if oauth2Err.GetError() != "invalid_request" || oauth2Err.GetErrorDescription() != "The user code is invalid" {
+		t.Errorf("OAuth2Error() = %s: %s", oauth2Err.GetError(), oauth2Err.GetErrorDescription())
+	}
+}
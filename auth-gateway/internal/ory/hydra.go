@@ -1,6 +1,7 @@
 package ory
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -39,3 +40,12 @@ func NewHydraPublic(publicUrl string, httpClient *http.Client) (*hydra.APIClient
 
 	return hydra.NewAPIClient(cfg), nil
 }
+
+func UnpackHydraGenericOpenApiError(err error) (*hydra.GenericOpenAPIError, bool) {
+	var genericErr *hydra.GenericOpenAPIError
+	if errors.As(err, &genericErr) {
+		return genericErr, true
+	}
+
+	return nil, false
+}
@@ -1,6 +1,7 @@
 package ory
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -29,6 +30,24 @@ func NewKratosPublic(URL string, httpClient *http.Client) (*kratos.APIClient, er
 	return client, nil
 }
 
+func NewKratosAdmin(adminURL string, httpClient *http.Client) (*kratos.APIClient, error) {
+	parsedURL, err := url.Parse(adminURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsedURL.Scheme == "" {
+		return nil, fmt.Errorf("kratos admin URL must have scheme, either http or https")
+	}
+
+	cfg := kratos.NewConfiguration()
+	cfg.Scheme = parsedURL.Scheme
+	cfg.Host = parsedURL.Host
+	cfg.HTTPClient = httpClient
+
+	return kratos.NewAPIClient(cfg), nil
+}
+
 func UnpackKratosGenericOpenApiError(err error) (*kratos.GenericOpenAPIError, bool) {
 	var genericErr *kratos.GenericOpenAPIError
 	if errors.As(err, &genericErr) {
@@ -52,3 +71,23 @@ func UnpackKratosGenericError(openApiErr *kratos.GenericOpenAPIError) (*kratos.G
 
 	return nil, false
 }
+
+// UnpackKratosRedirectBrowserTo returns where Kratos asks the browser to go
+// with a 422 browser_location_change_required. Kratos sends the error one
+// level shallower than the generated model expects, so the model is usually
+// missing and the body is read instead.
+func UnpackKratosRedirectBrowserTo(openApiErr *kratos.GenericOpenAPIError) (string, bool) {
+	if locationChange, ok := openApiErr.Model().(kratos.ErrorBrowserLocationChangeRequired); ok {
+		return locationChange.GetRedirectBrowserTo(), locationChange.RedirectBrowserTo != nil
+	}
+
+	var body struct {
+		RedirectBrowserTo string `json:"redirect_browser_to"`
+	}
+
+	if err := json.Unmarshal(openApiErr.Body(), &body); err != nil || body.RedirectBrowserTo == "" {
+		return "", false
+	}
+
+	return body.RedirectBrowserTo, true
+}
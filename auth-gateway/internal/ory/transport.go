@@ -15,9 +15,10 @@ type Clients struct {
 	HydraAdmin   *hydra.APIClient
 	HydraPublic  *hydra.APIClient
 	KratosPublic *kratos.APIClient
+	KratosAdmin  *kratos.APIClient
 }
 
-func NewClients(hydraAdminURL string, hydraPublicURL string, kratosPublicURL string) (*Clients, error) {
+func NewClients(hydraAdminURL string, hydraPublicURL string, kratosPublicURL string, kratosAdminURL string) (*Clients, error) {
 	client := defaultHTTPClient()
 
 	hydraAdmin, err := NewHydraAdmin(hydraAdminURL, client)
@@ -35,7 +36,17 @@ func NewClients(hydraAdminURL string, hydraPublicURL string, kratosPublicURL str
 		return nil, err
 	}
 
-	return &Clients{HydraAdmin: hydraAdmin, HydraPublic: hydraPublic, KratosPublic: kratosPublic}, nil
+	kratosAdmin, err := NewKratosAdmin(kratosAdminURL, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clients{
+		HydraAdmin:   hydraAdmin,
+		HydraPublic:  hydraPublic,
+		KratosPublic: kratosPublic,
+		KratosAdmin:  kratosAdmin,
+	}, nil
 }
 
 func defaultHTTPClient() *http.Client {
@@ -30,6 +30,21 @@ var (
 		code:   "csrf_violation",
 		msg:    "CSRF token did not match",
 	}
+	ErrUnauthorized = &err{
+		status: http.StatusUnauthorized,
+		code:   "unauthorized",
+		msg:    "No active session",
+	}
+	ErrInvalidCredentials = &err{
+		status: http.StatusUnauthorized,
+		code:   "invalid_credentials",
+		msg:    "Invalid or missing credentials",
+	}
+	ErrSessionRefreshRequired = &err{
+		status: http.StatusForbidden,
+		code:   "session_refresh_required",
+		msg:    "Sign in again to continue",
+	}
 	ErrNotFound = &err{
 		status: http.StatusNotFound,
 		code:   "not_found",
@@ -45,6 +60,61 @@ var (
 		code:   "flow_expired",
 		msg:    "Flow expired",
 	}
+	ErrSessionAlreadyAvailable = &err{
+		status: http.StatusBadRequest,
+		code:   "session_already_available",
+		msg:    "Already signed in",
+	}
+	ErrAAL2Required = &err{
+		status: http.StatusForbidden,
+		code:   "aal2_required",
+		msg:    "A second factor is required",
+	}
+	ErrMFAEnrollmentRequired = &err{
+		status: http.StatusForbidden,
+		code:   "mfa_enrollment_required",
+		msg:    "Set up a second factor to continue",
+	}
+	ErrCodeInvalid = &err{
+		status: http.StatusUnprocessableEntity,
+		code:   "code_invalid",
+		msg:    "The code is invalid or has already been used",
+	}
+	ErrCodeExpired = &err{
+		status: http.StatusUnprocessableEntity,
+		code:   "code_expired",
+		msg:    "The code has expired, request a new one",
+	}
+	ErrAccountNotFound = &err{
+		status: http.StatusNotFound,
+		code:   "account_not_found",
+		msg:    "No account exists for this address",
+	}
+	ErrAccountExists = &err{
+		status: http.StatusConflict,
+		code:   "account_exists",
+		msg:    "An account with this address already exists",
+	}
+	ErrAddressNotVerified = &err{
+		status: http.StatusForbidden,
+		code:   "address_not_verified",
+		msg:    "The address has not been verified yet",
+	}
+	ErrTOTPInvalid = &err{
+		status: http.StatusUnprocessableEntity,
+		code:   "totp_invalid",
+		msg:    "The authenticator code is invalid",
+	}
+	ErrBackupCodeInvalid = &err{
+		status: http.StatusUnprocessableEntity,
+		code:   "backup_code_invalid",
+		msg:    "The backup code is invalid or has already been used",
+	}
+	ErrTooManyRequests = &err{
+		status: http.StatusTooManyRequests,
+		code:   "too_many_requests",
+		msg:    "Too many attempts, try again later",
+	}
 	ErrInternal = &err{
 		status: http.StatusInternalServerError,
 		code:   "internal_error",
@@ -61,3 +131,28 @@ func NewValidation(details map[string]string) HTTPError {
 		details: details,
 	}
 }
+
+// detailed overrides the details of an error, errors.Is still matches the
+// original error.
+type detailed struct {
+	HTTPError
+	details any
+}
+
+func (e *detailed) Details() any  { return e.details }
+func (e *detailed) Unwrap() error { return e.HTTPError }
+
+// WithDetails returns the error with details attached, e.g. the flow the
+// client has to continue with.
+func WithDetails(e HTTPError, details any) HTTPError {
+	return &detailed{HTTPError: e, details: details}
+}
+
+// Helper for OAuth2 request errors, code is the OAuth2 error code.
+func NewBadRequest(code string, msg string) HTTPError {
+	return &err{
+		status: http.StatusBadRequest,
+		code:   code,
+		msg:    msg,
+	}
+}
@@ -3,6 +3,7 @@ package response
 import (
 	"encoding/json"
 	"errors"
+	"html/template"
 	"net/http"
 )
 
@@ -52,6 +53,38 @@ func WriteError(w http.ResponseWriter, e error) {
 	})
 }
 
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Sign-in error</title></head>
+<body>
+<h1>Something went wrong</h1>
+<p>{{.Message}}</p>
+<p><small>Error code: {{.Code}}</small></p>
+</body>
+</html>
+`))
+
+// WriteErrorPage renders the error as a minimal HTML page, for requests
+// that come straight from the browser and not from the UI.
+func WriteErrorPage(w http.ResponseWriter, e error) {
+	status := http.StatusInternalServerError
+	payload := errPayload{
+		Code:    "internal_server_error",
+		Message: "An internal server error occurred. Our team has been notified.",
+	}
+
+	var he HTTPError
+	if ok := errors.As(e, &he); ok {
+		status = he.Status()
+		payload.Code = he.Code()
+		payload.Message = he.Error()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = errorPage.Execute(w, payload)
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
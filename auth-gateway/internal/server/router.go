@@ -5,9 +5,13 @@ import (
 	"net/http"
 	"runtime/debug"
 
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/claims"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/config"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/handlers/admin"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/handlers/auth"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/handlers/forwardauth"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/service"
 	"github.com/julienschmidt/httprouter"
 	"github.com/rs/cors"
@@ -24,6 +28,8 @@ func NewRouter(
 	appConfig *config.AppConfig,
 	idp service.IDPService,
 	oauth2 service.OAuth2Service,
+	clients service.ClientService,
+	claimsMapping claims.Mapping,
 	logger *zap.Logger,
 ) http.Handler {
 	r := httprouter.New()
@@ -34,14 +40,26 @@ func NewRouter(
 	r.GET("/readyz", readyz)
 
 	// Create auth handler
-	authHandler := auth.NewHandler(idp, oauth2)
+	authHandler := auth.NewHandler(idp, oauth2, claimsMapping, model.AALPolicy{
+		Clients:   appConfig.MFAConfig.AAL2Clients,
+		ACRValues: appConfig.MFAConfig.AAL2ACRValues,
+	}, appConfig.LoginConfig.OIDCReturnURL)
 	authHandler.RegisterRoutes(r)
 
+	forwardAuthHandler := forwardauth.NewHandler(oauth2, appConfig.ForwardAuthConfig.CacheTTL)
+	forwardAuthHandler.RegisterRoutes(r)
+
+	// The admin API is only exposed when an API key is configured
+	if appConfig.AdminConfig.APIKey != "" {
+		adminHandler := admin.NewHandler(clients, appConfig.AdminConfig.APIKey)
+		adminHandler.RegisterRoutes(r)
+	}
+
 	n := negroni.New()
 	n.Use(negroni.HandlerFunc(middleware.RequestIDMiddleware))
 	n.Use(negroni.HandlerFunc(middleware.LoggingMiddleware(logger)))
 	n.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://127.0.0.1:5555"},
+		AllowedOrigins:   appConfig.ServerConfig.CORSAllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
 		AllowCredentials: true,
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/config"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	hydra "github.com/ory/hydra-client-go/v2"
+	"go.uber.org/zap"
+)
+
+type clientServiceHydra struct {
+	hydraAdmin *hydra.APIClient
+	policy     config.ClientPolicy
+}
+
+func NewClientServiceHydra(hydraAdmin *hydra.APIClient, policy config.ClientPolicy) ClientService {
+	return &clientServiceHydra{hydraAdmin: hydraAdmin, policy: policy}
+}
+
+func toClient(client *hydra.OAuth2Client) model.Client {
+	return model.Client{
+		ID:                      client.GetClientId(),
+		Name:                    client.GetClientName(),
+		RedirectURIs:            client.RedirectUris,
+		PostLogoutRedirectURIs:  client.PostLogoutRedirectUris,
+		GrantTypes:              client.GrantTypes,
+		ResponseTypes:           client.ResponseTypes,
+		Scope:                   client.GetScope(),
+		Audience:                client.Audience,
+		TokenEndpointAuthMethod: client.GetTokenEndpointAuthMethod(),
+		SkipConsent:             client.GetSkipConsent(),
+		CreatedAt:               client.CreatedAt,
+		UpdatedAt:               client.UpdatedAt,
+	}
+}
+
+func toHydraClient(form *model.ClientForm) hydra.OAuth2Client {
+	client := hydra.NewOAuth2Client()
+	applyClientForm(client, form)
+
+	return *client
+}
+
+// applyClientForm sets the fields of the form on the client and keeps the
+// ones the form does not model. An empty token endpoint auth method keeps
+// the current one, Hydra would fall back to client_secret_basic and make a
+// public client confidential.
+func applyClientForm(client *hydra.OAuth2Client, form *model.ClientForm) {
+	client.SetClientName(form.Name)
+	client.SetRedirectUris(form.RedirectURIs)
+	client.SetPostLogoutRedirectUris(form.PostLogoutRedirectURIs)
+	client.SetGrantTypes(form.GrantTypes)
+	client.SetResponseTypes(form.ResponseTypes)
+	client.SetScope(form.Scope)
+	client.SetAudience(form.Audience)
+	client.SetSkipConsent(form.SkipConsent)
+
+	if form.TokenEndpointAuthMethod != "" {
+		client.SetTokenEndpointAuthMethod(form.TokenEndpointAuthMethod)
+	}
+}
+
+// validateClientForm checks the form against the gateway client policy.
+func (c *clientServiceHydra) validateClientForm(form *model.ClientForm) error {
+	validationErrors := make(map[string]string)
+
+	if len(form.GrantTypes) == 0 {
+		validationErrors["grant_types"] = "required"
+	}
+
+	for _, grantType := range form.GrantTypes {
+		if !slices.Contains(c.policy.AllowedGrantTypes, grantType) {
+			validationErrors["grant_types"] = "not allowed: " + grantType
+		}
+	}
+
+	for _, responseType := range form.ResponseTypes {
+		if !slices.Contains(c.policy.AllowedResponseTypes, responseType) {
+			validationErrors["response_types"] = "not allowed: " + responseType
+		}
+	}
+
+	if slices.Contains(form.GrantTypes, "authorization_code") && len(form.RedirectURIs) == 0 {
+		validationErrors["redirect_uris"] = "required for authorization_code"
+	}
+
+	for _, redirectURI := range form.RedirectURIs {
+		if reason := c.checkRedirectURI(redirectURI); reason != "" {
+			validationErrors["redirect_uris"] = reason + ": " + redirectURI
+		}
+	}
+
+	for _, redirectURI := range form.PostLogoutRedirectURIs {
+		if reason := c.checkRedirectURI(redirectURI); reason != "" {
+			validationErrors["post_logout_redirect_uris"] = reason + ": " + redirectURI
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		return response.NewValidation(validationErrors)
+	}
+
+	return nil
+}
+
+// checkRedirectURI returns why the URI is not allowed, or an empty string.
+func (c *clientServiceHydra) checkRedirectURI(raw string) string {
+	redirectURL, err := url.Parse(raw)
+	if err != nil || !redirectURL.IsAbs() || redirectURL.Host == "" {
+		return "must be an absolute URL"
+	}
+
+	if redirectURL.Fragment != "" {
+		return "must not contain a fragment"
+	}
+
+	hostname := redirectURL.Hostname()
+	isLoopback := hostname == "localhost" || hostname == "127.0.0.1" || hostname == "::1"
+
+	switch redirectURL.Scheme {
+	case "https":
+	case "http":
+		if !isLoopback && !c.policy.AllowInsecureRedirects {
+			return "must use https"
+		}
+	default:
+		return "unsupported scheme"
+	}
+
+	if len(c.policy.AllowedRedirectHosts) > 0 && !slices.Contains(c.policy.AllowedRedirectHosts, hostname) {
+		return "host not allowed"
+	}
+
+	return ""
+}
+
+// nextPageToken reads the page_token of the rel="next" link Hydra sends in
+// the Link header.
+func nextPageToken(res *http.Response) string {
+	if res == nil {
+		return ""
+	}
+
+	for _, link := range strings.Split(res.Header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 || !strings.Contains(parts[1], `rel="next"`) {
+			continue
+		}
+
+		linkURL, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+		if err != nil {
+			continue
+		}
+
+		return linkURL.Query().Get("page_token")
+	}
+
+	return ""
+}
+
+func (c *clientServiceHydra) ListClients(ctx context.Context, form *model.ListClientsForm) (model.ClientList, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("listing clients", zap.Int64("page_size", form.PageSize), zap.String("page_token", form.PageToken))
+
+	req := c.hydraAdmin.OAuth2API.ListOAuth2Clients(ctx)
+
+	if form.PageSize > 0 {
+		req = req.PageSize(form.PageSize)
+	}
+
+	if form.PageToken != "" {
+		req = req.PageToken(form.PageToken)
+	}
+
+	clients, res, err := req.Execute()
+
+	if err != nil {
+		logger.Error("failed to list clients", zap.Error(err))
+		return model.ClientList{}, handleHydraError(err, res)
+	}
+
+	list := model.ClientList{
+		Clients:       make([]model.Client, 0, len(clients)),
+		NextPageToken: nextPageToken(res),
+	}
+
+	for i := range clients {
+		list.Clients = append(list.Clients, toClient(&clients[i]))
+	}
+
+	return list, nil
+}
+
+func (c *clientServiceHydra) GetClient(ctx context.Context, id string) (model.Client, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting client", zap.String("client_id", id))
+
+	client, res, err := c.hydraAdmin.OAuth2API.GetOAuth2Client(ctx, id).Execute()
+
+	if err != nil {
+		logger.Error("failed to get client", zap.String("client_id", id), zap.Error(err))
+		return model.Client{}, handleHydraError(err, res)
+	}
+
+	return toClient(client), nil
+}
+
+func (c *clientServiceHydra) CreateClient(ctx context.Context, form *model.ClientForm) (model.Client, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("creating client", zap.String("client_name", form.Name), zap.Strings("grant_types", form.GrantTypes))
+
+	if err := c.validateClientForm(form); err != nil {
+		logger.Error("client violates policy", zap.Error(err))
+		return model.Client{}, err
+	}
+
+	client, res, err := c.hydraAdmin.OAuth2API.CreateOAuth2Client(ctx).
+		OAuth2Client(toHydraClient(form)).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to create client", zap.Error(err))
+		return model.Client{}, handleHydraError(err, res)
+	}
+
+	logger.Info("client created", zap.String("client_id", client.GetClientId()))
+
+	created := toClient(client)
+	// The secret is only ever returned here, Hydra stores a hash of it.
+	created.Secret = client.GetClientSecret()
+
+	return created, nil
+}
+
+func (c *clientServiceHydra) UpdateClient(ctx context.Context, id string, form *model.ClientForm) (model.Client, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("updating client", zap.String("client_id", id))
+
+	if err := c.validateClientForm(form); err != nil {
+		logger.Error("client violates policy", zap.Error(err))
+		return model.Client{}, err
+	}
+
+	// Hydra replaces the whole client, so the form is applied to the
+	// current client to keep what it does not model.
+	client, res, err := c.hydraAdmin.OAuth2API.GetOAuth2Client(ctx, id).Execute()
+
+	if err != nil {
+		logger.Error("failed to get client for update", zap.String("client_id", id), zap.Error(err))
+		return model.Client{}, handleHydraError(err, res)
+	}
+
+	applyClientForm(client, form)
+
+	client, res, err = c.hydraAdmin.OAuth2API.SetOAuth2Client(ctx, id).
+		OAuth2Client(*client).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to update client", zap.String("client_id", id), zap.Error(err))
+		return model.Client{}, handleHydraError(err, res)
+	}
+
+	return toClient(client), nil
+}
+
+func (c *clientServiceHydra) DeleteClient(ctx context.Context, id string) error {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("deleting client", zap.String("client_id", id))
+
+	res, err := c.hydraAdmin.OAuth2API.DeleteOAuth2Client(ctx, id).Execute()
+
+	if err != nil {
+		logger.Error("failed to delete client", zap.String("client_id", id), zap.Error(err))
+		return handleHydraError(err, res)
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/config"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+)
+
+func TestValidateClientForm(t *testing.T) {
+	policy := config.ClientPolicy{
+		AllowedGrantTypes:    []string{"authorization_code", "refresh_token", "client_credentials"},
+		AllowedResponseTypes: []string{"code"},
+	}
+
+	restricted := policy
+	restricted.AllowedRedirectHosts = []string{"app.example.com"}
+
+	insecure := policy
+	insecure.AllowInsecureRedirects = true
+
+	tests := []struct {
+		name    string
+		policy  config.ClientPolicy
+		form    model.ClientForm
+		wantErr map[string]string
+	}{
+		{
+			name:   "authorization code client",
+			policy: policy,
+			form: model.ClientForm{
+				GrantTypes:    []string{"authorization_code", "refresh_token"},
+				ResponseTypes: []string{"code"},
+				RedirectURIs:  []string{"https://app.example.com/callback"},
+			},
+		},
+		{
+			name:   "machine client without redirect",
+			policy: policy,
+			form:   model.ClientForm{GrantTypes: []string{"client_credentials"}},
+		},
+		{
+			name:    "no grant type",
+			policy:  policy,
+			form:    model.ClientForm{},
+			wantErr: map[string]string{"grant_types": "required"},
+		},
+		{
+			name:    "grant type not allowed",
+			policy:  policy,
+			form:    model.ClientForm{GrantTypes: []string{"implicit"}},
+			wantErr: map[string]string{"grant_types": "not allowed: implicit"},
+		},
+		{
+			name:   "response type not allowed",
+			policy: policy,
+			form: model.ClientForm{
+				GrantTypes:    []string{"authorization_code"},
+				ResponseTypes: []string{"token"},
+				RedirectURIs:  []string{"https://app.example.com/callback"},
+			},
+			wantErr: map[string]string{"response_types": "not allowed: token"},
+		},
+		{
+			name:    "authorization code without redirect",
+			policy:  policy,
+			form:    model.ClientForm{GrantTypes: []string{"authorization_code"}},
+			wantErr: map[string]string{"redirect_uris": "required for authorization_code"},
+		},
+		{
+			name:   "relative redirect",
+			policy: policy,
+			form: model.ClientForm{
+				GrantTypes:   []string{"authorization_code"},
+				RedirectURIs: []string{"/callback"},
+			},
+			wantErr: map[string]string{"redirect_uris": "must be an absolute URL: /callback"},
+		},
+		{
+			name:   "redirect with fragment",
+			policy: policy,
+			form: model.ClientForm{
+				GrantTypes:   []string{"authorization_code"},
+				RedirectURIs: []string{"https://app.example.com/callback#token"},
+			},
+			wantErr: map[string]string{"redirect_uris": "must not contain a fragment: https://app.example.com/callback#token"},
+		},
+		{
+			name:   "http redirect",
+			policy: policy,
+			form: model.ClientForm{
+				GrantTypes:   []string{"authorization_code"},
+				RedirectURIs: []string{"http://app.example.com/callback"},
+			},
+			wantErr: map[string]string{"redirect_uris": "must use https: http://app.example.com/callback"},
+		},
+		{
+			name:   "http redirect to loopback",
+			policy: policy,
+			form: model.ClientForm{
+				GrantTypes:   []string{"authorization_code"},
+				RedirectURIs: []string{"http://127.0.0.1:5555/callback", "http://localhost:5555/callback"},
+			},
+		},
+		{
+			name:   "http redirect with insecure redirects allowed",
+			policy: insecure,
+			form: model.ClientForm{
+				GrantTypes:   []string{"authorization_code"},
+				RedirectURIs: []string{"http://app.example.com/callback"},
+			},
+		},
+		{
+			name:   "custom scheme",
+			policy: policy,
+			form: model.ClientForm{
+				GrantTypes:   []string{"authorization_code"},
+				RedirectURIs: []string{"javascript://app.example.com/callback"},
+			},
+			wantErr: map[string]string{"redirect_uris": "unsupported scheme: javascript://app.example.com/callback"},
+		},
+		{
+			name:   "redirect host not allowed",
+			policy: restricted,
+			form: model.ClientForm{
+				GrantTypes:   []string{"authorization_code"},
+				RedirectURIs: []string{"https://evil.example.com/callback"},
+			},
+			wantErr: map[string]string{"redirect_uris": "host not allowed: https://evil.example.com/callback"},
+		},
+		{
+			name:   "redirect host allowed",
+			policy: restricted,
+			form: model.ClientForm{
+				GrantTypes:   []string{"authorization_code"},
+				RedirectURIs: []string{"https://app.example.com/callback"},
+			},
+		},
+		{
+			name:   "post logout redirect",
+			policy: policy,
+			form: model.ClientForm{
+				GrantTypes:             []string{"client_credentials"},
+				PostLogoutRedirectURIs: []string{"http://app.example.com/logout"},
+			},
+			wantErr: map[string]string{"post_logout_redirect_uris": "must use https: http://app.example.com/logout"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &clientServiceHydra{policy: tt.policy}
+			err := c.validateClientForm(&tt.form)
+
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("validateClientForm() error = %v, want nil", err)
+				}
+
+				return
+			}
+
+			var httpErr response.HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("validateClientForm() error = %v, want validation error", err)
+			}
+
+			if got := httpErr.Details(); !reflect.DeepEqual(got, tt.wantErr) {
+				t.Errorf("validateClientForm() details = %v, want %v", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateClientKeepsUnmodelledFields(t *testing.T) {
+	var replaced map[string]any
+
+	hydraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/clients/app" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]any{
+				"client_id":                  "app",
+				"client_name":                "App",
+				"grant_types":                []string{"authorization_code"},
+				"redirect_uris":              []string{"https://app.example.com/callback"},
+				"token_endpoint_auth_method": "none",
+				"metadata":                   map[string]any{"team": "platform"},
+			})
+		case http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&replaced); err != nil {
+				t.Errorf("decode replaced client: %v", err)
+			}
+
+			json.NewEncoder(w).Encode(replaced)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer hydraServer.Close()
+
+	hydraAdmin, err := ory.NewHydraAdmin(hydraServer.URL, hydraServer.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClientServiceHydra(hydraAdmin, config.ClientPolicy{
+		AllowedGrantTypes:    []string{"authorization_code"},
+		AllowedResponseTypes: []string{"code"},
+	})
+
+	client, err := c.UpdateClient(context.Background(), "app", &model.ClientForm{
+		Name:          "Renamed",
+		GrantTypes:    []string{"authorization_code"},
+		ResponseTypes: []string{"code"},
+		RedirectURIs:  []string{"https://app.example.com/callback"},
+	})
+
+	if err != nil {
+		t.Fatalf("UpdateClient() error = %v", err)
+	}
+
+	if client.Name != "Renamed" {
+		t.Errorf("client name = %q, want %q", client.Name, "Renamed")
+	}
+
+	if got := replaced["token_endpoint_auth_method"]; got != "none" {
+		t.Errorf("replaced token_endpoint_auth_method = %v, want none", got)
+	}
+
+	if got := replaced["metadata"]; !reflect.DeepEqual(got, map[string]any{"team": "platform"}) {
+		t.Errorf("replaced metadata = %v, want the current metadata", got)
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	kratos "github.com/ory/kratos-client-go"
+)
+
+// kratosMessageAccountNotFound is the UI message Kratos adds to a code login
+// flow when no identity has the identifier.
+const kratosMessageAccountNotFound int64 = 4000035
+
+// kratosErrorIDs translates the IDs of Kratos generic errors.
+var kratosErrorIDs = map[string]response.HTTPError{
+	"security_csrf_violation":    response.ErrCSRF,
+	"security_identity_mismatch": response.ErrInvalidFlow,
+	"self_service_flow_expired":  response.ErrFlowExpired,
+	"self_service_flow_replaced": response.ErrInvalidFlow,
+	"session_inactive":           response.ErrUnauthorized,
+	"session_refresh_required":   response.ErrSessionRefreshRequired,
+	"session_already_available":  response.ErrSessionAlreadyAvailable,
+	"session_aal2_required":      response.ErrAAL2Required,
+}
+
+// kratosMessageIDs translates the UI message IDs Kratos puts on a flow it
+// rejected. Kratos has no message for an expired code, the code lives as
+// long as its flow, so an expired login, registration, recovery or
+// verification flow means the code expired.
+//
+// See https://www.ory.sh/docs/kratos/concepts/ui-messages for the IDs.
+var kratosMessageIDs = map[int64]response.HTTPError{
+	// Generic validation
+	4000006:                      response.ErrInvalidCredentials,
+	4000007:                      response.ErrAccountExists,
+	4000008:                      response.ErrTOTPInvalid,
+	4000010:                      response.ErrAddressNotVerified,
+	4000012:                      response.ErrBackupCodeInvalid,
+	4000014:                      response.ErrMFAEnrollmentRequired,
+	4000016:                      response.ErrBackupCodeInvalid,
+	4000027:                      response.ErrAccountExists,
+	kratosMessageAccountNotFound: response.ErrAccountNotFound,
+
+	// Login
+	4010001: response.ErrCodeExpired,
+	4010008: response.ErrCodeInvalid,
+	4010010: response.ErrAccountNotFound,
+
+	// Registration
+	4040001: response.ErrCodeExpired,
+	4040003: response.ErrCodeInvalid,
+
+	// Settings
+	4050001: response.ErrFlowExpired,
+
+	// Recovery
+	4060004: response.ErrCodeInvalid,
+	4060005: response.ErrCodeExpired,
+	4060006: response.ErrCodeInvalid,
+
+	// Verification
+	4070001: response.ErrCodeInvalid,
+	4070005: response.ErrCodeExpired,
+	4070006: response.ErrCodeInvalid,
+}
+
+func handleKratosErrorCode(code int64) error {
+	switch code {
+	case 401:
+		return fmt.Errorf("unauthorized: %w", response.ErrUnauthorized)
+	case 404:
+		return fmt.Errorf("not found: %w", response.ErrNotFound)
+	case 410:
+		return fmt.Errorf("gone: %w", response.ErrFlowExpired)
+	case 429:
+		return fmt.Errorf("too many requests: %w", response.ErrTooManyRequests)
+	}
+
+	return nil
+}
+
+func handleKratosErrorId(errId string) error {
+	if e, ok := kratosErrorIDs[errId]; ok {
+		return fmt.Errorf("%s: %w", errId, e)
+	}
+
+	return nil
+}
+
+// handleKratosUiMessages returns the translation of the first flow or field
+// message that has one.
+func handleKratosUiMessages(ui kratos.UiContainer) response.HTTPError {
+	for _, message := range ui.Messages {
+		if e, ok := kratosMessageIDs[message.Id]; ok {
+			return e
+		}
+	}
+
+	for _, node := range ui.Nodes {
+		for _, message := range node.Messages {
+			if e, ok := kratosMessageIDs[message.Id]; ok {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+// flowUiError turns the flow Kratos returns for an invalid submission into
+// an error. Known messages become their typed error, other messages a
+// validation error; both carry the Kratos messages as details.
+func flowUiError(flowID string, ui kratos.UiContainer) error {
+	flowUI := toFlowUI(ui, nil, nil)
+
+	if e := handleKratosUiMessages(ui); e != nil {
+		return response.WithDetails(e, flowUI)
+	}
+
+	if flowUI.HasErrors() {
+		return response.WithDetails(response.NewValidation(nil), flowUI)
+	}
+
+	return fmt.Errorf("flow %s rejected the submission: %w", flowID, response.ErrInvalidFlow)
+}
+
+// handleKratosOpenAPIError translates an error response of Kratos, which is
+// either a generic error or the flow with messages on it.
+func handleKratosOpenAPIError(openApiErr *kratos.GenericOpenAPIError) error {
+	switch flow := openApiErr.Model().(type) {
+	case kratos.LoginFlow:
+		return flowUiError(flow.Id, flow.Ui)
+	case kratos.RegistrationFlow:
+		return flowUiError(flow.Id, flow.Ui)
+	case kratos.RecoveryFlow:
+		return flowUiError(flow.Id, flow.Ui)
+	case kratos.VerificationFlow:
+		return flowUiError(flow.Id, flow.Ui)
+	case kratos.SettingsFlow:
+		return flowUiError(flow.Id, flow.Ui)
+	}
+
+	genericErr, ok := ory.UnpackKratosGenericError(openApiErr)
+	if !ok {
+		return openApiErr
+	}
+
+	if errId := genericErr.Id; errId != nil {
+		if e := handleKratosErrorId(*errId); e != nil {
+			return e
+		}
+	}
+
+	if code := genericErr.Code; code != nil {
+		if e := handleKratosErrorCode(*code); e != nil {
+			return e
+		}
+	}
+
+	return openApiErr
+}
+
+// handleKratosError translates any error returned by the Kratos client.
+func handleKratosError(err error) error {
+	openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+	if !ok {
+		return err
+	}
+
+	return handleKratosOpenAPIError(openApiErr)
+}
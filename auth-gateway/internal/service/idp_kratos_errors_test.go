@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	kratos "github.com/ory/kratos-client-go"
+)
+
+func TestHandleKratosErrorCode(t *testing.T) {
+	tests := []struct {
+		code int64
+		want error
+	}{
+		{code: 401, want: response.ErrUnauthorized},
+		{code: 404, want: response.ErrNotFound},
+		{code: 410, want: response.ErrFlowExpired},
+		{code: 429, want: response.ErrTooManyRequests},
+		{code: 400},
+		{code: 500},
+	}
+
+	for _, tt := range tests {
+		got := handleKratosErrorCode(tt.code)
+
+		if tt.want == nil {
+			if got != nil {
+				t.Errorf("handleKratosErrorCode(%d) = %v, want nil", tt.code, got)
+			}
+
+			continue
+		}
+
+		if !errors.Is(got, tt.want) {
+			t.Errorf("handleKratosErrorCode(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestHandleKratosErrorId(t *testing.T) {
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{id: "security_csrf_violation", want: response.ErrCSRF},
+		{id: "security_identity_mismatch", want: response.ErrInvalidFlow},
+		{id: "self_service_flow_expired", want: response.ErrFlowExpired},
+		{id: "self_service_flow_replaced", want: response.ErrInvalidFlow},
+		{id: "session_inactive", want: response.ErrUnauthorized},
+		{id: "session_refresh_required", want: response.ErrSessionRefreshRequired},
+		{id: "session_already_available", want: response.ErrSessionAlreadyAvailable},
+		{id: "session_aal2_required", want: response.ErrAAL2Required},
+		{id: "browser_location_change_required"},
+		{id: ""},
+	}
+
+	for _, tt := range tests {
+		got := handleKratosErrorId(tt.id)
+
+		if tt.want == nil {
+			if got != nil {
+				t.Errorf("handleKratosErrorId(%q) = %v, want nil", tt.id, got)
+			}
+
+			continue
+		}
+
+		if !errors.Is(got, tt.want) {
+			t.Errorf("handleKratosErrorId(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestHandleKratosUiMessages(t *testing.T) {
+	tests := []struct {
+		id   int64
+		want response.HTTPError
+	}{
+		{id: 4000006, want: response.ErrInvalidCredentials},
+		{id: 4000007, want: response.ErrAccountExists},
+		{id: 4000008, want: response.ErrTOTPInvalid},
+		{id: 4000010, want: response.ErrAddressNotVerified},
+		{id: 4000012, want: response.ErrBackupCodeInvalid},
+		{id: 4000016, want: response.ErrBackupCodeInvalid},
+		{id: 4000027, want: response.ErrAccountExists},
+		{id: 4000035, want: response.ErrAccountNotFound},
+		{id: 4010001, want: response.ErrCodeExpired},
+		{id: 4010008, want: response.ErrCodeInvalid},
+		{id: 4010010, want: response.ErrAccountNotFound},
+		{id: 4040001, want: response.ErrCodeExpired},
+		{id: 4040003, want: response.ErrCodeInvalid},
+		{id: 4050001, want: response.ErrFlowExpired},
+		{id: 4060004, want: response.ErrCodeInvalid},
+		{id: 4060005, want: response.ErrCodeExpired},
+		{id: 4060006, want: response.ErrCodeInvalid},
+		{id: 4070001, want: response.ErrCodeInvalid},
+		{id: 4070005, want: response.ErrCodeExpired},
+		{id: 4070006, want: response.ErrCodeInvalid},
+		{id: 1010014},
+		{id: 4000002},
+	}
+
+	for _, tt := range tests {
+		message := kratos.UiText{Id: tt.id, Type: "error"}
+
+		containers := map[string]kratos.UiContainer{
+			"flow": {Messages: []kratos.UiText{message}},
+			"node": {Nodes: []kratos.UiNode{inputNode("code", message)}},
+		}
+
+		for on, ui := range containers {
+			if got := handleKratosUiMessages(ui); got != tt.want {
+				t.Errorf("handleKratosUiMessages(%d on %s) = %v, want %v", tt.id, on, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestFlowUiError(t *testing.T) {
+	invalidCode := kratos.UiText{Id: 4010008, Type: "error", Text: "The login code is invalid or has already been used. Please try again."}
+	missingEmail := kratos.UiText{Id: 4000002, Type: "error", Text: "Property email is missing."}
+
+	tests := []struct {
+		name        string
+		ui          kratos.UiContainer
+		want        error
+		wantCode    string
+		wantDetails any
+	}{
+		{
+			name:     "known message",
+			ui:       kratos.UiContainer{Nodes: []kratos.UiNode{inputNode("code", invalidCode)}},
+			want:     response.ErrCodeInvalid,
+			wantCode: "code_invalid",
+			wantDetails: model.FlowUI{FieldErrors: map[string][]model.UIMessage{
+				"code": {{ID: 4010008, Type: "error", Text: invalidCode.Text}},
+			}},
+		},
+		{
+			name:     "unknown error message",
+			ui:       kratos.UiContainer{Nodes: []kratos.UiNode{inputNode("traits.email", missingEmail)}},
+			wantCode: "validation_error",
+			wantDetails: model.FlowUI{FieldErrors: map[string][]model.UIMessage{
+				"traits.email": {{ID: 4000002, Type: "error", Text: missingEmail.Text}},
+			}},
+		},
+		{
+			name:     "no error message",
+			ui:       kratos.UiContainer{},
+			want:     response.ErrInvalidFlow,
+			wantCode: response.ErrInvalidFlow.Code(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := flowUiError("flow", tt.ui)
+
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("flowUiError() = %v, want %v", err, tt.want)
+			}
+
+			var httpErr response.HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("flowUiError() = %v, want an HTTPError", err)
+			}
+
+			if httpErr.Code() != tt.wantCode {
+				t.Errorf("flowUiError() code = %q, want %q", httpErr.Code(), tt.wantCode)
+			}
+
+			if tt.wantDetails != nil && !reflect.DeepEqual(httpErr.Details(), tt.wantDetails) {
+				t.Errorf("flowUiError() details = %+v, want %+v", httpErr.Details(), tt.wantDetails)
+			}
+		})
+	}
+}
@@ -16,105 +16,170 @@ import (
 
 type authServiceKratos struct {
 	kratosPublic *kratos.APIClient
+	kratosAdmin  *kratos.APIClient
 }
 
-func NewAuthServiceKratos(client *kratos.APIClient) IDPService {
-	return &authServiceKratos{kratosPublic: client}
+func NewAuthServiceKratos(client *kratos.APIClient, adminClient *kratos.APIClient) IDPService {
+	return &authServiceKratos{kratosPublic: client, kratosAdmin: adminClient}
 }
 
-func findCsrfInNodes(nodes []kratos.UiNode) string {
-	for _, node := range nodes {
-		if node.Type == "input" && node.Attributes.UiNodeInputAttributes.Name == "csrf_token" {
-			if value, ok := node.Attributes.UiNodeInputAttributes.Value.(string); ok {
-				return value
-			}
-		}
+func toSession(session *kratos.Session) model.Session {
+	if session == nil {
+		return model.Session{}
 	}
 
-	return ""
-}
-
-func findIdentifierInNodes(nodes []kratos.UiNode) string {
-	for _, node := range nodes {
-		if node.Type == "input" && node.Attributes.UiNodeInputAttributes.Name == "identifier" {
-			return node.Attributes.UiNodeInputAttributes.Value.(string)
+	var methods []string
+	for _, method := range session.AuthenticationMethods {
+		if m := method.GetMethod(); m != "" {
+			methods = append(methods, m)
 		}
 	}
 
-	return ""
-}
-
-func handleKratosErrorCode(code int64) error {
-	if code == 404 {
-		return fmt.Errorf("not found: %w", response.ErrNotFound)
+	var identityID string
+	if session.Identity != nil {
+		identityID = session.Identity.Id
 	}
 
-	return nil
-}
-
-func handleKratosErrorId(errId string) error {
-	if errId == "security_csrf_violation" {
-		return fmt.Errorf("csrf violation: %w", response.ErrCSRF)
+	return model.Session{
+		ID:              session.Id,
+		IdentityID:      identityID,
+		AuthenticatedAt: session.GetAuthenticatedAt(),
+		AAL:             string(session.GetAuthenticatorAssuranceLevel()),
+		Methods:         methods,
 	}
+}
 
-	if errId == "self_service_flow_expired" {
-		return fmt.Errorf("flow expired: %w", response.ErrFlowExpired)
+func toIdentity(identity *kratos.Identity) model.Identity {
+	if identity == nil {
+		return model.Identity{}
 	}
 
-	return nil
-}
+	traits, _ := identity.Traits.(map[string]any)
+	metadataPublic, _ := identity.MetadataPublic.(map[string]any)
 
-func handleKratosOpenAPIError(openApiErr *kratos.GenericOpenAPIError) error {
-	genericErr, ok := ory.UnpackKratosGenericError(openApiErr)
-	if !ok {
-		return openApiErr
+	addresses := make([]model.VerifiableAddress, 0, len(identity.VerifiableAddresses))
+	for _, address := range identity.VerifiableAddresses {
+		addresses = append(addresses, model.VerifiableAddress{
+			Value:    address.Value,
+			Via:      address.Via,
+			Verified: address.Verified,
+		})
 	}
 
-	if errId := genericErr.Id; errId != nil {
-		if e := handleKratosErrorId(*errId); e != nil {
-			return e
-		}
+	return model.Identity{
+		ID:                  identity.Id,
+		SchemaID:            identity.SchemaId,
+		Traits:              traits,
+		MetadataPublic:      metadataPublic,
+		VerifiableAddresses: addresses,
 	}
+}
 
-	if code := genericErr.Code; code != nil {
-		if e := handleKratosErrorCode(*code); e != nil {
-			return e
-		}
+func toLoginFlow(flow *kratos.LoginFlow) model.LoginFlow {
+	login := model.LoginFlow{
+		ID:             flow.Id,
+		CsrfToken:      findCsrfInNodes(flow.Ui.GetNodes()),
+		Identifier:     findInputValueInNodes(flow.Ui.GetNodes(), "identifier"),
+		Methods:        findMethodsInNodes(flow.Ui.GetNodes()),
+		LoginChallenge: flow.GetOauth2LoginChallenge(),
+		IssuedAt:       &flow.IssuedAt,
+		FlowUI:         toFlowUI(flow.Ui, flow.State, &flow.ExpiresAt),
 	}
 
-	return nil
+	login.PasskeyOptions = findJSONInNodes(flow.Ui.GetNodes(), "passkey_challenge")
+	login.Providers = findProvidersInNodes(flow.Ui.GetNodes())
+	login.RequestedAAL = string(flow.GetRequestedAal())
+	login.Client = toFlowClient(flow.Oauth2LoginRequest)
+
+	return login
 }
 
-func (s *authServiceKratos) CreateLoginFlow(ctx context.Context, challenge string, cookies []*http.Cookie) (model.LoginFlow, []*http.Cookie, error) {
+func (s *authServiceKratos) CreateLoginFlow(ctx context.Context, challenge string, cookies []*http.Cookie, form *model.CreateLoginFlowForm) (model.LoginFlow, []*http.Cookie, error) {
 	logger := middleware.GetLoggerFrom(ctx)
-	logger.Info("creating login flow", zap.String("challenge", challenge), zap.Int("cookies_count", len(cookies)))
+	logger.Info("creating login flow",
+		zap.String("challenge", challenge),
+		zap.Bool("refresh", form.Refresh),
+		zap.String("aal", form.AAL),
+		zap.String("return_to", form.ReturnTo),
+		zap.Int("cookies_count", len(cookies)))
 
-	if challenge == "" {
+	// Without a challenge the flow only re-authenticates or steps up the
+	// current session, e.g. before a privileged settings change.
+	if challenge == "" && !form.Refresh && form.AAL == "" {
 		logger.Error("challenge is required")
 		return model.LoginFlow{}, nil, response.NewValidation(map[string]string{"challenge": "required"})
 	}
 
-	logger.Debug("sending create browser login flow request to Kratos")
-	req := s.kratosPublic.FrontendAPI.CreateBrowserLoginFlow(ctx)
-	req.Cookie(util.ConcatCookies(cookies))
-	req.LoginChallenge(challenge)
+	var (
+		flow *kratos.LoginFlow
+		res  *http.Response
+		err  error
+	)
+
+	if sessionToken, native := nativeFlow(ctx); native {
+		// API flows do not know the login challenge, the gateway accepts
+		// it itself once the code is submitted.
+		logger.Debug("sending create native login flow request to Kratos")
+		req := s.kratosPublic.FrontendAPI.CreateNativeLoginFlow(ctx)
+
+		if sessionToken != "" {
+			req = req.XSessionToken(sessionToken)
+		}
+
+		if form.Refresh {
+			req = req.Refresh(true)
+		}
+
+		if form.AAL != "" {
+			req = req.Aal(form.AAL)
+		}
+
+		flow, res, err = req.Execute()
+	} else {
+		logger.Debug("sending create browser login flow request to Kratos")
+		req := s.kratosPublic.FrontendAPI.CreateBrowserLoginFlow(ctx)
+		req = req.Cookie(util.ConcatCookies(cookies))
+
+		if challenge != "" {
+			req = req.LoginChallenge(challenge)
+		}
+
+		if form.Refresh {
+			req = req.Refresh(true)
+		}
+
+		if form.AAL != "" {
+			req = req.Aal(form.AAL)
+		}
+
+		if form.ReturnTo != "" {
+			req = req.ReturnTo(form.ReturnTo)
+		}
+
+		flow, res, err = req.Execute()
+	}
 
-	flow, res, err := req.Execute()
 	if err != nil {
 		logger.Error("failed to create login flow", zap.Error(err))
-		return model.LoginFlow{}, nil, err
+		return model.LoginFlow{}, nil, handleKratosError(err)
 	}
 
-	csrfToken := findCsrfInNodes(flow.Ui.GetNodes())
-	identifier := findIdentifierInNodes(flow.Ui.GetNodes())
+	loginFlow := toLoginFlow(flow)
+
+	// Kratos offers no method on an AAL2 flow when the identity has no
+	// second factor set up.
+	if form.AAL == model.AAL2 && len(loginFlow.Methods) == 0 {
+		logger.Info("identity has no second factor", zap.String("flow_id", flow.Id))
+		return model.LoginFlow{}, res.Cookies(), response.ErrMFAEnrollmentRequired
+	}
 
 	logger.Info("login flow created successfully",
 		zap.String("flow_id", flow.Id),
-		zap.Bool("has_csrf_token", csrfToken != ""),
-		zap.Bool("has_identifier", identifier != ""),
+		zap.Bool("has_csrf_token", loginFlow.CsrfToken != ""),
+		zap.Bool("has_identifier", loginFlow.Identifier != ""),
 		zap.Int("response_cookies_count", len(res.Cookies())))
 
-	return model.LoginFlow{ID: flow.Id, CsrfToken: csrfToken, Identifier: identifier}, res.Cookies(), nil
+	return loginFlow, res.Cookies(), nil
 }
 
 func (s *authServiceKratos) GetLoginFlow(ctx context.Context, id string, cookies []*http.Cookie) (model.LoginFlow, []*http.Cookie, error) {
@@ -146,20 +211,15 @@ func (s *authServiceKratos) GetLoginFlow(ctx context.Context, id string, cookies
 		return model.LoginFlow{}, nil, handledErr
 	}
 
-	csrfToken := findCsrfInNodes(flow.Ui.GetNodes())
-	identifier := findIdentifierInNodes(flow.Ui.GetNodes())
+	loginFlow := toLoginFlow(flow)
 
 	logger.Info("login flow retrieved successfully",
 		zap.String("flow_id", flow.Id),
-		zap.Bool("has_csrf_token", csrfToken != ""),
-		zap.Bool("has_identifier", identifier != ""),
+		zap.Bool("has_csrf_token", loginFlow.CsrfToken != ""),
+		zap.Bool("has_identifier", loginFlow.Identifier != ""),
 		zap.Int("response_cookies_count", len(res.Cookies())))
 
-	return model.LoginFlow{
-		ID:         flow.Id,
-		CsrfToken:  csrfToken,
-		Identifier: identifier,
-	}, res.Cookies(), nil
+	return loginFlow, res.Cookies(), nil
 }
 
 func (s *authServiceKratos) SendLoginEmailCode(
@@ -186,7 +246,7 @@ func (s *authServiceKratos) SendLoginEmailCode(
 		validationErrors["identifier"] = "required"
 	}
 
-	if form.CsrfToken == "" {
+	if _, native := nativeFlow(ctx); form.CsrfToken == "" && !native {
 		validationErrors["csrf_token"] = "required"
 	}
 
@@ -221,20 +281,28 @@ func (s *authServiceKratos) SendLoginEmailCode(
 			logger.Info("login flow state from error response", zap.String("state", stateStr), zap.String("flow_id", loginFlow.Id))
 			if stateStr == "sent_email" {
 				logger.Info("email code sent successfully", zap.String("flow_id", loginFlow.Id), zap.String("identifier", form.Identifier))
-				return model.LoginFlow{
-					ID:         loginFlow.Id,
-					CsrfToken:  findCsrfInNodes(loginFlow.Ui.GetNodes()),
-					Identifier: form.Identifier,
-				}, nil, nil
+				sent := toLoginFlow(&loginFlow)
+				sent.Identifier = form.Identifier
+				sent.Type = model.FlowTypeLogin
+
+				return sent, res.Cookies(), nil
+			}
+
+			if hasUiMessage(loginFlow.Ui, kratosMessageAccountNotFound) {
+				logger.Info("account does not exist, continuing with registration", zap.String("flow_id", loginFlow.Id))
+				return s.startCodeRegistration(ctx, loginFlow.GetOauth2LoginChallenge(), cookies, form.Identifier)
 			}
 
 			logger.Error("unexpected login flow state", zap.String("state", stateStr), zap.String("flow_id", loginFlow.Id))
-			return model.LoginFlow{}, res.Cookies(), response.ErrNotFound
+			return model.LoginFlow{}, res.Cookies(), flowUiError(loginFlow.Id, loginFlow.Ui)
 		}
 
-		logger.Error("failed to extract login flow from error response")
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.LoginFlow{}, res.Cookies(), handledErr
 	}
 
+	logger.Error("login flow did not send a code", zap.String("flow_id", flowID))
 	return model.LoginFlow{}, res.Cookies(), response.ErrInternal
 }
 
@@ -243,7 +311,7 @@ func (s *authServiceKratos) SubmitLoginEmailCode(
 	flowID string,
 	cookies []*http.Cookie,
 	form *model.SubmitLoginEmailCodeForm,
-) (model.SubmitLoginEmailCodeResponse, []*http.Cookie, error) {
+) (model.SubmitLoginResponse, []*http.Cookie, error) {
 	logger := middleware.GetLoggerFrom(ctx)
 	logger.Info("submitting login email code",
 		zap.String("flow_id", flowID),
@@ -252,7 +320,7 @@ func (s *authServiceKratos) SubmitLoginEmailCode(
 		zap.Bool("has_csrf_token", form.CsrfToken != ""),
 		zap.Int("cookies_count", len(cookies)))
 
-	var validationErrors map[string]string = make(map[string]string)
+	validationErrors := loginSubmitValidation(ctx, flowID, form.CsrfToken)
 
 	if form.Identifier == "" {
 		validationErrors["identifier"] = "required"
@@ -262,46 +330,533 @@ func (s *authServiceKratos) SubmitLoginEmailCode(
 		validationErrors["code"] = "required"
 	}
 
-	if form.CsrfToken == "" {
-		validationErrors["csrf_token"] = "required"
-	}
-
 	if len(validationErrors) > 0 {
 		logger.Error("validation failed for submit login email code", zap.Any("errors", validationErrors))
-		return model.SubmitLoginEmailCodeResponse{}, nil, response.NewValidation(validationErrors)
+		return model.SubmitLoginResponse{}, nil, response.NewValidation(validationErrors)
 	}
 
-	logger.Debug("sending update login flow request to Kratos for code verification")
-	login, res, err := s.kratosPublic.FrontendAPI.UpdateLoginFlow(ctx).
-		Cookie(util.ConcatCookies(cookies)).
-		Flow(flowID).UpdateLoginFlowBody(kratos.UpdateLoginFlowBody{
+	if form.Type == model.FlowTypeRegistration {
+		registration, outCookies, err := s.SubmitRegistrationCode(ctx, flowID, cookies, &model.SubmitRegistrationCodeForm{
+			Traits:    map[string]any{"email": form.Identifier},
+			Code:      form.Code,
+			CsrfToken: form.CsrfToken,
+		})
+
+		if err != nil {
+			return model.SubmitLoginResponse{}, outCookies, err
+		}
+
+		return model.SubmitLoginResponse{
+			Session:      registration.Session,
+			SessionToken: registration.SessionToken,
+		}, outCookies, nil
+	}
+
+	return s.submitLoginFlow(ctx, flowID, cookies, kratos.UpdateLoginFlowBody{
 		UpdateLoginFlowWithCodeMethod: &kratos.UpdateLoginFlowWithCodeMethod{
 			Method:     "code",
 			Code:       &form.Code,
 			Identifier: &form.Identifier,
 			CsrfToken:  form.CsrfToken,
 		},
-	}).Execute()
+	})
+}
+
+// loginSubmitValidation checks the fields every login method needs, native
+// flows have no CSRF token.
+func loginSubmitValidation(ctx context.Context, flowID string, csrfToken string) map[string]string {
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if _, native := nativeFlow(ctx); csrfToken == "" && !native {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	return validationErrors
+}
+
+// submitLoginFlow submits one login method on the flow and returns the
+// session it signed in. A native flow is submitted with the session token
+// of the app, so a refresh or a second factor is added to that session.
+func (s *authServiceKratos) submitLoginFlow(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	body kratos.UpdateLoginFlowBody,
+) (model.SubmitLoginResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	sessionToken, _ := nativeFlow(ctx)
+
+	logger.Debug("sending update login flow request to Kratos", zap.String("flow_id", flowID))
+	req := s.kratosPublic.FrontendAPI.UpdateLoginFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).
+		UpdateLoginFlowBody(body)
+
+	if sessionToken != "" {
+		req = req.XSessionToken(sessionToken)
+	}
+
+	login, res, err := req.Execute()
 
 	if err != nil {
-		logger.Error("failed to submit login email code", zap.String("flow_id", flowID), zap.Error(err))
+		logger.Error("failed to submit login flow", zap.String("flow_id", flowID), zap.Error(err))
 		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
 
 		if !ok {
-			return model.SubmitLoginEmailCodeResponse{}, nil, err
+			return model.SubmitLoginResponse{}, nil, err
 		}
 
 		handledErr := handleKratosOpenAPIError(openApiErr)
-		logger.Error("handled Kratos OpenAPI error for code submission", zap.Error(err), zap.Error(handledErr))
-		return model.SubmitLoginEmailCodeResponse{}, res.Cookies(), handledErr
+		logger.Error("handled Kratos OpenAPI error for login submission", zap.Error(err), zap.Error(handledErr))
+		return model.SubmitLoginResponse{}, res.Cookies(), handledErr
 	}
 
-	logger.Info("login email code submitted successfully",
+	logger.Info("login flow submitted successfully",
 		zap.String("flow_id", flowID),
 		zap.String("session_id", login.Session.Id),
+		zap.String("aal", string(login.Session.GetAuthenticatorAssuranceLevel())),
+		zap.Int("response_cookies_count", len(res.Cookies())))
+
+	// Kratos keeps the session token of a native session, only a new
+	// session comes with one.
+	if token := login.GetSessionToken(); token != "" {
+		sessionToken = token
+	}
+
+	return model.SubmitLoginResponse{
+		Session:      toSession(&login.Session),
+		SessionToken: sessionToken,
+	}, res.Cookies(), nil
+}
+
+// startCodeRegistration continues an email code login for an email that
+// has no account yet: it creates a registration flow for the same login
+// challenge and sends the code from there.
+func (s *authServiceKratos) startCodeRegistration(
+	ctx context.Context,
+	challenge string,
+	cookies []*http.Cookie,
+	email string,
+) (model.LoginFlow, []*http.Cookie, error) {
+	flow, outCookies, err := s.CreateRegistrationFlow(ctx, challenge)
+	if err != nil {
+		return model.LoginFlow{}, nil, err
+	}
+
+	// The new flow comes with a fresh CSRF cookie that the browser does
+	// not have yet.
+	sent, sentCookies, err := s.SendRegistrationCode(ctx, flow.ID, util.MergeCookies(cookies, outCookies), &model.SendRegistrationCodeForm{
+		Traits:    map[string]any{"email": email},
+		CsrfToken: flow.CsrfToken,
+	})
+
+	outCookies = append(outCookies, sentCookies...)
+
+	if err != nil {
+		return model.LoginFlow{}, outCookies, err
+	}
+
+	return model.LoginFlow{
+		ID:         sent.ID,
+		CsrfToken:  sent.CsrfToken,
+		Identifier: email,
+		Type:       model.FlowTypeRegistration,
+		FlowUI:     sent.FlowUI,
+	}, outCookies, nil
+}
+
+func toRegistrationFlow(flow *kratos.RegistrationFlow) model.RegistrationFlow {
+	registration := model.RegistrationFlow{
+		ID:        flow.Id,
+		CsrfToken: findCsrfInNodes(flow.Ui.GetNodes()),
+		Traits:    findTraitsInNodes(flow.Ui.GetNodes()),
+		FlowUI:    toFlowUI(flow.Ui, flow.State, &flow.ExpiresAt),
+	}
+
+	registration.Client = toFlowClient(flow.Oauth2LoginRequest)
+
+	return registration
+}
+
+func (s *authServiceKratos) CreateRegistrationFlow(ctx context.Context, challenge string) (model.RegistrationFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("creating registration flow", zap.String("challenge", challenge))
+
+	var (
+		flow *kratos.RegistrationFlow
+		res  *http.Response
+		err  error
+	)
+
+	if _, native := nativeFlow(ctx); native {
+		// Like native logins, the gateway accepts the challenge itself.
+		logger.Debug("sending create native registration flow request to Kratos")
+		flow, res, err = s.kratosPublic.FrontendAPI.
+			CreateNativeRegistrationFlow(ctx).
+			Execute()
+	} else {
+		if challenge == "" {
+			logger.Error("challenge is required")
+			return model.RegistrationFlow{}, nil, response.NewValidation(map[string]string{"challenge": "required"})
+		}
+
+		// Kratos does not read cookies here, the response sets a fresh CSRF
+		// cookie.
+		logger.Debug("sending create browser registration flow request to Kratos")
+		flow, res, err = s.kratosPublic.FrontendAPI.
+			CreateBrowserRegistrationFlow(ctx).
+			LoginChallenge(challenge).
+			Execute()
+	}
+
+	if err != nil {
+		logger.Error("failed to create registration flow", zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.RegistrationFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.RegistrationFlow{}, nil, handledErr
+	}
+
+	logger.Info("registration flow created successfully",
+		zap.String("flow_id", flow.Id),
 		zap.Int("response_cookies_count", len(res.Cookies())))
 
-	return model.SubmitLoginEmailCodeResponse{
-		Session: model.Session{ID: login.Session.Id},
+	return toRegistrationFlow(flow), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) GetRegistrationFlow(ctx context.Context, id string, cookies []*http.Cookie) (model.RegistrationFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting registration flow", zap.String("flow_id", id), zap.Int("cookies_count", len(cookies)))
+
+	if id == "" {
+		logger.Error("flow ID is required")
+		return model.RegistrationFlow{}, nil, response.NewValidation(map[string]string{"id": "required"})
+	}
+
+	logger.Debug("sending get registration flow request to Kratos")
+	flow, res, err := s.kratosPublic.FrontendAPI.
+		GetRegistrationFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Id(id).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to get registration flow", zap.String("flow_id", id), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.RegistrationFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.RegistrationFlow{}, nil, handledErr
+	}
+
+	logger.Info("registration flow retrieved successfully", zap.String("flow_id", flow.Id))
+
+	return toRegistrationFlow(flow), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) SendRegistrationCode(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SendRegistrationCodeForm,
+) (model.RegistrationFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("sending registration code",
+		zap.String("flow_id", flowID),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if len(form.Traits) == 0 {
+		validationErrors["traits"] = "required"
+	}
+
+	if _, native := nativeFlow(ctx); form.CsrfToken == "" && !native {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for send registration code", zap.Any("errors", validationErrors))
+		return model.RegistrationFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	// The traits are validated by Kratos against the identity schema,
+	// violations come back as messages on the trait nodes.
+	logger.Debug("sending update registration flow request to Kratos for email code")
+	_, res, err := s.kratosPublic.FrontendAPI.UpdateRegistrationFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).UpdateRegistrationFlowBody(kratos.UpdateRegistrationFlowBody{
+		UpdateRegistrationFlowWithCodeMethod: &kratos.UpdateRegistrationFlowWithCodeMethod{
+			Method:    "code",
+			Traits:    form.Traits,
+			CsrfToken: &form.CsrfToken,
+		},
+	}).Execute()
+
+	if err == nil {
+		logger.Error("registration flow did not send a code", zap.String("flow_id", flowID))
+		return model.RegistrationFlow{}, res.Cookies(), response.ErrInternal
+	}
+
+	openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+	if !ok {
+		return model.RegistrationFlow{}, nil, err
+	}
+
+	// Like the login flow, Kratos answers with a 400 and the flow in state
+	// sent_email once the code is on its way.
+	if registrationFlow, ok := openApiErr.Model().(kratos.RegistrationFlow); ok {
+		if state, _ := registrationFlow.State.(string); state == "sent_email" {
+			logger.Info("registration code sent successfully", zap.String("flow_id", registrationFlow.Id))
+			return toRegistrationFlow(&registrationFlow), res.Cookies(), nil
+		}
+
+		logger.Error("unexpected registration flow state", zap.Any("state", registrationFlow.State), zap.String("flow_id", registrationFlow.Id))
+		return model.RegistrationFlow{}, res.Cookies(), flowUiError(registrationFlow.Id, registrationFlow.Ui)
+	}
+
+	handledErr := handleKratosOpenAPIError(openApiErr)
+	logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+	return model.RegistrationFlow{}, res.Cookies(), handledErr
+}
+
+// SubmitRegistrationCode completes the registration. The session hook
+// signs the new user in.
+func (s *authServiceKratos) SubmitRegistrationCode(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SubmitRegistrationCodeForm,
+) (model.SubmitRegistrationCodeResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("submitting registration code",
+		zap.String("flow_id", flowID),
+		zap.Bool("has_code", form.Code != ""),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if len(form.Traits) == 0 {
+		validationErrors["traits"] = "required"
+	}
+
+	if form.Code == "" {
+		validationErrors["code"] = "required"
+	}
+
+	if _, native := nativeFlow(ctx); form.CsrfToken == "" && !native {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for submit registration code", zap.Any("errors", validationErrors))
+		return model.SubmitRegistrationCodeResponse{}, nil, response.NewValidation(validationErrors)
+	}
+
+	logger.Debug("sending update registration flow request to Kratos for code verification")
+	registration, res, err := s.kratosPublic.FrontendAPI.UpdateRegistrationFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).UpdateRegistrationFlowBody(kratos.UpdateRegistrationFlowBody{
+		UpdateRegistrationFlowWithCodeMethod: &kratos.UpdateRegistrationFlowWithCodeMethod{
+			Method:    "code",
+			Code:      &form.Code,
+			Traits:    form.Traits,
+			CsrfToken: &form.CsrfToken,
+		},
+	}).Execute()
+
+	if err != nil {
+		logger.Error("failed to submit registration code", zap.String("flow_id", flowID), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.SubmitRegistrationCodeResponse{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error for registration code submission", zap.Error(err), zap.Error(handledErr))
+		return model.SubmitRegistrationCodeResponse{}, res.Cookies(), handledErr
+	}
+
+	if registration.Session == nil {
+		logger.Error("registration did not issue a session, is the session hook enabled?", zap.String("flow_id", flowID))
+		return model.SubmitRegistrationCodeResponse{}, res.Cookies(), response.ErrInternal
+	}
+
+	logger.Info("registration completed successfully",
+		zap.String("flow_id", flowID),
+		zap.String("identity_id", registration.Identity.Id),
+		zap.String("session_id", registration.Session.Id))
+
+	return model.SubmitRegistrationCodeResponse{
+		Session:      toSession(registration.Session),
+		SessionToken: registration.GetSessionToken(),
+	}, res.Cookies(), nil
+}
+
+func (s *authServiceKratos) ToSession(ctx context.Context, cookies []*http.Cookie) (model.Session, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("checking session", zap.Int("cookies_count", len(cookies)))
+
+	req := s.kratosPublic.FrontendAPI.ToSession(ctx)
+
+	if sessionToken, native := nativeFlow(ctx); native {
+		if sessionToken == "" {
+			logger.Info("no session token")
+			return model.Session{}, nil, fmt.Errorf("no session token: %w", response.ErrUnauthorized)
+		}
+
+		req = req.XSessionToken(sessionToken)
+	} else {
+		req = req.Cookie(util.ConcatCookies(cookies))
+	}
+
+	logger.Debug("sending whoami request to Kratos")
+	session, res, err := req.Execute()
+
+	if err != nil {
+		logger.Info("no valid session", zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.Session{}, nil, err
+		}
+
+		return model.Session{}, nil, handleKratosOpenAPIError(openApiErr)
+	}
+
+	if !session.GetActive() {
+		logger.Info("session is not active", zap.String("session_id", session.Id))
+		return model.Session{}, nil, fmt.Errorf("session is not active: %w", response.ErrUnauthorized)
+	}
+
+	logger.Info("session is valid", zap.String("session_id", session.Id))
+
+	return toSession(session), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) GetIdentity(ctx context.Context, id string) (model.Identity, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting identity", zap.String("identity_id", id))
+
+	if id == "" {
+		logger.Error("identity ID is required")
+		return model.Identity{}, response.NewValidation(map[string]string{"identity_id": "required"})
+	}
+
+	logger.Debug("sending get identity request to Kratos admin")
+	identity, _, err := s.kratosAdmin.IdentityAPI.GetIdentity(ctx, id).Execute()
+
+	if err != nil {
+		logger.Error("failed to get identity", zap.String("identity_id", id), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.Identity{}, err
+		}
+
+		return model.Identity{}, handleKratosOpenAPIError(openApiErr)
+	}
+
+	return toIdentity(identity), nil
+}
+
+// CreateLogoutFlow creates a browser logout flow. Native sessions have no
+// logout flow, their session token is used as the logout token instead.
+func (s *authServiceKratos) CreateLogoutFlow(ctx context.Context, cookies []*http.Cookie) (model.LogoutFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("creating logout flow", zap.Int("cookies_count", len(cookies)))
+
+	logger.Debug("sending create browser logout flow request to Kratos")
+	flow, res, err := s.kratosPublic.FrontendAPI.
+		CreateBrowserLogoutFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to create logout flow", zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.LogoutFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.LogoutFlow{}, nil, handledErr
+	}
+
+	logger.Info("logout flow created successfully", zap.Int("response_cookies_count", len(res.Cookies())))
+
+	return model.LogoutFlow{
+		LogoutToken: flow.LogoutToken,
+		LogoutURL:   flow.LogoutUrl,
 	}, res.Cookies(), nil
 }
+
+func (s *authServiceKratos) PerformLogout(ctx context.Context, token string, cookies []*http.Cookie) ([]*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("performing logout", zap.Int("cookies_count", len(cookies)))
+
+	if token == "" {
+		logger.Error("logout token is required")
+		return nil, response.NewValidation(map[string]string{"token": "required"})
+	}
+
+	var (
+		res *http.Response
+		err error
+	)
+
+	if _, native := nativeFlow(ctx); native {
+		logger.Debug("sending perform native logout request to Kratos")
+		res, err = s.kratosPublic.FrontendAPI.
+			PerformNativeLogout(ctx).
+			PerformNativeLogoutBody(kratos.PerformNativeLogoutBody{SessionToken: token}).
+			Execute()
+	} else {
+		logger.Debug("sending update logout flow request to Kratos")
+		res, err = s.kratosPublic.FrontendAPI.
+			UpdateLogoutFlow(ctx).
+			Cookie(util.ConcatCookies(cookies)).
+			Token(token).
+			Execute()
+	}
+
+	if err != nil {
+		logger.Error("failed to perform logout", zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return nil, handledErr
+	}
+
+	logger.Info("logout performed successfully", zap.Int("response_cookies_count", len(res.Cookies())))
+
+	return res.Cookies(), nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
+)
+
+// newKratosTestService returns a Kratos backed IDPService whose public and
+// admin APIs are both served by handler.
+func newKratosTestService(t *testing.T, handler http.Handler) IDPService {
+	t.Helper()
+
+	kratosServer := httptest.NewServer(handler)
+	t.Cleanup(kratosServer.Close)
+
+	client, err := ory.NewKratosPublic(kratosServer.URL, kratosServer.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewAuthServiceKratos(client, client)
+}
+
+// kratosFlow builds the JSON of a Kratos login or registration flow with the
+// fields the client requires.
+func kratosFlow(id, flowType, state string, messages []map[string]any, nodes ...map[string]any) map[string]any {
+	if messages == nil {
+		messages = []map[string]any{}
+	}
+
+	if nodes == nil {
+		nodes = []map[string]any{}
+	}
+
+	return map[string]any{
+		"id":          id,
+		"type":        flowType,
+		"state":       state,
+		"expires_at":  "2030-01-01T00:00:00Z",
+		"issued_at":   "2025-01-01T00:00:00Z",
+		"request_url": "http://kratos/self-service/login/browser",
+		"ui": map[string]any{
+			"action":   "http://kratos/self-service/login?flow=" + id,
+			"method":   "POST",
+			"messages": messages,
+			"nodes":    nodes,
+		},
+	}
+}
+
+func kratosInputNode(name, value string) map[string]any {
+	return map[string]any{
+		"type":     "input",
+		"group":    "default",
+		"messages": []map[string]any{},
+		"meta":     map[string]any{},
+		"attributes": map[string]any{
+			"node_type": "input",
+			"name":      name,
+			"type":      "hidden",
+			"value":     value,
+			"disabled":  false,
+		},
+	}
+}
+
+func kratosMessage(id int64, text string) map[string]any {
+	return map[string]any{"id": id, "text": text, "type": "error"}
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, body any) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		t.Errorf("encode response: %v", err)
+	}
+}
+
+func TestSendLoginEmailCodeStartsRegistrationForUnknownAccount(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /self-service/login", func(w http.ResponseWriter, r *http.Request) {
+		flow := kratosFlow("login-flow", "browser", "choose_method",
+			[]map[string]any{kratosMessage(kratosMessageAccountNotFound, "This account does not exist or has not setup sign in with code.")},
+			kratosInputNode("csrf_token", "login-csrf"))
+		flow["oauth2_login_challenge"] = "challenge"
+
+		writeJSON(t, w, http.StatusBadRequest, flow)
+	})
+
+	mux.HandleFunc("GET /self-service/registration/browser", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("login_challenge"); got != "challenge" {
+			t.Errorf("login_challenge = %q, want %q", got, "challenge")
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: "csrf_token_registration", Value: "registration-cookie"})
+		writeJSON(t, w, http.StatusOK, kratosFlow("registration-flow", "browser", "choose_method", nil,
+			kratosInputNode("csrf_token", "registration-csrf")))
+	})
+
+	mux.HandleFunc("POST /self-service/registration", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("flow"); got != "registration-flow" {
+			t.Errorf("flow = %q, want %q", got, "registration-flow")
+		}
+
+		if cookie, err := r.Cookie("csrf_token_registration"); err != nil || cookie.Value != "registration-cookie" {
+			t.Errorf("registration CSRF cookie was not sent: %v", err)
+		}
+
+		var body struct {
+			Method    string            `json:"method"`
+			CsrfToken string            `json:"csrf_token"`
+			Traits    map[string]string `json:"traits"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+
+		if body.Method != "code" || body.CsrfToken != "registration-csrf" || body.Traits["email"] != "new@example.com" {
+			t.Errorf("body = %+v, want code method with the email trait", body)
+		}
+
+		writeJSON(t, w, http.StatusBadRequest, kratosFlow("registration-flow", "browser", "sent_email", nil,
+			kratosInputNode("csrf_token", "registration-csrf")))
+	})
+
+	s := newKratosTestService(t, mux)
+
+	flow, cookies, err := s.SendLoginEmailCode(context.Background(), "login-flow", nil, &model.SendLoginEmailCodeForm{
+		Identifier: "new@example.com",
+		CsrfToken:  "login-csrf",
+	})
+
+	if err != nil {
+		t.Fatalf("SendLoginEmailCode() error = %v", err)
+	}
+
+	if flow.ID != "registration-flow" || flow.Type != model.FlowTypeRegistration {
+		t.Errorf("SendLoginEmailCode() flow = %s %q, want registration flow %q", flow.Type, flow.ID, "registration-flow")
+	}
+
+	if flow.CsrfToken != "registration-csrf" || flow.Identifier != "new@example.com" {
+		t.Errorf("SendLoginEmailCode() csrf_token = %q, identifier = %q", flow.CsrfToken, flow.Identifier)
+	}
+
+	if len(cookies) == 0 || cookies[0].Name != "csrf_token_registration" {
+		t.Errorf("SendLoginEmailCode() cookies = %v, want the registration CSRF cookie", cookies)
+	}
+}
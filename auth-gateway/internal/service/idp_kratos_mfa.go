@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	kratos "github.com/ory/kratos-client-go"
+	"go.uber.org/zap"
+)
+
+// toTOTPSettings reads the totp nodes of a settings flow. An identity with
+// an authenticator app gets the unlink button, one without gets the QR code
+// and its secret.
+func toTOTPSettings(nodes []kratos.UiNode) *model.TOTPSettings {
+	var totp *model.TOTPSettings
+
+	for _, node := range nodes {
+		if node.Group != "totp" {
+			continue
+		}
+
+		if totp == nil {
+			totp = &model.TOTPSettings{}
+		}
+
+		attributes := node.Attributes
+
+		switch {
+		case attributes.UiNodeImageAttributes != nil && attributes.UiNodeImageAttributes.Id == "totp_qr":
+			totp.QRCode = attributes.UiNodeImageAttributes.Src
+		case attributes.UiNodeTextAttributes != nil && attributes.UiNodeTextAttributes.Id == "totp_secret_key":
+			totp.Secret = attributes.UiNodeTextAttributes.Text.Text
+		case attributes.UiNodeInputAttributes != nil && attributes.UiNodeInputAttributes.Name == "totp_unlink":
+			totp.Linked = true
+		}
+	}
+
+	return totp
+}
+
+// SubmitLoginTOTP completes an AAL2 login flow with the code of the
+// authenticator app. The session is upgraded to aal2.
+func (s *authServiceKratos) SubmitLoginTOTP(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SubmitLoginSecondFactorForm,
+) (model.SubmitLoginResponse, []*http.Cookie, error) {
+	return s.submitLoginSecondFactor(ctx, "totp", flowID, cookies, form, kratos.UpdateLoginFlowBody{
+		UpdateLoginFlowWithTotpMethod: &kratos.UpdateLoginFlowWithTotpMethod{
+			Method:    "totp",
+			TotpCode:  form.Code,
+			CsrfToken: &form.CsrfToken,
+		},
+	})
+}
+
+// LinkSettingsTOTP sets up the authenticator app shown as QR code on the
+// settings flow, the code proves the app was set up.
+func (s *authServiceKratos) LinkSettingsTOTP(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.LinkSettingsTOTPForm,
+) (model.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("linking totp", zap.String("flow_id", flowID), zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.Code == "" {
+		validationErrors["code"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for link totp", zap.Any("errors", validationErrors))
+		return model.SettingsFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	flow, outCookies, err := s.updateSettingsFlow(ctx, flowID, cookies, kratos.UpdateSettingsFlowBody{
+		UpdateSettingsFlowWithTotpMethod: &kratos.UpdateSettingsFlowWithTotpMethod{
+			Method:    "totp",
+			TotpCode:  &form.Code,
+			CsrfToken: &form.CsrfToken,
+		},
+	})
+
+	if err != nil {
+		return model.SettingsFlow{}, outCookies, err
+	}
+
+	logger.Info("totp linked", zap.String("flow_id", flow.Id))
+
+	return toSettingsFlow(flow), outCookies, nil
+}
+
+// UnlinkSettingsTOTP removes the authenticator app of the identity.
+func (s *authServiceKratos) UnlinkSettingsTOTP(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.UnlinkSettingsTOTPForm,
+) (model.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("unlinking totp", zap.String("flow_id", flowID), zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for unlink totp", zap.Any("errors", validationErrors))
+		return model.SettingsFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	unlink := true
+
+	flow, outCookies, err := s.updateSettingsFlow(ctx, flowID, cookies, kratos.UpdateSettingsFlowBody{
+		UpdateSettingsFlowWithTotpMethod: &kratos.UpdateSettingsFlowWithTotpMethod{
+			Method:     "totp",
+			TotpUnlink: &unlink,
+			CsrfToken:  &form.CsrfToken,
+		},
+	})
+
+	if err != nil {
+		return model.SettingsFlow{}, outCookies, err
+	}
+
+	logger.Info("totp unlinked", zap.String("flow_id", flow.Id))
+
+	return toSettingsFlow(flow), outCookies, nil
+}
+
+// toLookupSecretSettings reads the lookup_secret nodes of a settings flow.
+// The codes are left out, only GenerateSettingsLookupSecret and
+// RevealSettingsLookupSecret return them.
+func toLookupSecretSettings(nodes []kratos.UiNode) *model.LookupSecretSettings {
+	var lookup *model.LookupSecretSettings
+
+	for _, node := range nodes {
+		if node.Group != "lookup_secret" {
+			continue
+		}
+
+		if lookup == nil {
+			lookup = &model.LookupSecretSettings{}
+		}
+
+		if attributes := inputAttributes(node); attributes != nil {
+			switch attributes.Name {
+			case "lookup_secret_reveal", "lookup_secret_disable":
+				lookup.Enabled = true
+			case "lookup_secret_confirm":
+				lookup.ConfirmRequired = true
+			}
+		}
+	}
+
+	return lookup
+}
+
+// findLookupSecretCodes reads the codes from the context of the codes text,
+// which has one message per code: the code itself or when it was used.
+func findLookupSecretCodes(nodes []kratos.UiNode) []model.LookupSecretCode {
+	var text *kratos.UiText
+
+	for _, node := range nodes {
+		if attributes := node.Attributes.UiNodeTextAttributes; attributes != nil && attributes.Id == "lookup_secret_codes" {
+			text = &attributes.Text
+			break
+		}
+	}
+
+	if text == nil {
+		return nil
+	}
+
+	secrets, _ := text.Context["secrets"].([]any)
+	codes := make([]model.LookupSecretCode, 0, len(secrets))
+
+	for _, secret := range secrets {
+		message, _ := secret.(map[string]any)
+		messageContext, _ := message["context"].(map[string]any)
+
+		var code model.LookupSecretCode
+		code.Code, _ = messageContext["secret"].(string)
+
+		if usedAt, ok := messageContext["used_at"].(string); ok {
+			if t, err := time.Parse(time.RFC3339, usedAt); err == nil {
+				code.UsedAt = &t
+			}
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes
+}
+
+// SubmitLoginLookupSecret completes an AAL2 login flow with a backup code
+// instead of the authenticator app. Every code works once.
+func (s *authServiceKratos) SubmitLoginLookupSecret(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SubmitLoginSecondFactorForm,
+) (model.SubmitLoginResponse, []*http.Cookie, error) {
+	return s.submitLoginSecondFactor(ctx, "lookup_secret", flowID, cookies, form, kratos.UpdateLoginFlowBody{
+		UpdateLoginFlowWithLookupSecretMethod: &kratos.UpdateLoginFlowWithLookupSecretMethod{
+			Method:       "lookup_secret",
+			LookupSecret: form.Code,
+			CsrfToken:    &form.CsrfToken,
+		},
+	})
+}
+
+// submitLoginSecondFactor submits the code of a second factor, which is
+// added to the session of the first one.
+func (s *authServiceKratos) submitLoginSecondFactor(
+	ctx context.Context,
+	method string,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SubmitLoginSecondFactorForm,
+	body kratos.UpdateLoginFlowBody,
+) (model.SubmitLoginResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("submitting login second factor",
+		zap.String("method", method),
+		zap.String("flow_id", flowID),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := loginSubmitValidation(ctx, flowID, form.CsrfToken)
+
+	if form.Code == "" {
+		validationErrors["code"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for submit login second factor", zap.String("method", method), zap.Any("errors", validationErrors))
+		return model.SubmitLoginResponse{}, nil, response.NewValidation(validationErrors)
+	}
+
+	return s.submitLoginFlow(ctx, flowID, cookies, body)
+}
+
+// GenerateSettingsLookupSecret generates new backup codes. They replace
+// the old codes once confirmed and are only returned here, so the user
+// sees them once.
+func (s *authServiceKratos) GenerateSettingsLookupSecret(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.UpdateSettingsLookupSecretForm,
+) (model.SettingsFlow, []*http.Cookie, error) {
+	regenerate := true
+
+	flow, outCookies, err := s.updateSettingsLookupSecret(ctx, "generate", flowID, cookies, form, kratos.UpdateSettingsFlowWithLookupMethod{
+		LookupSecretRegenerate: &regenerate,
+	})
+
+	if err != nil {
+		return model.SettingsFlow{}, outCookies, err
+	}
+
+	return withLookupSecretCodes(flow), outCookies, nil
+}
+
+// RevealSettingsLookupSecret shows the confirmed backup codes again, used
+// codes come with the time they were used. Kratos takes the reveal button
+// off a flow once it revealed the codes, so they are revealed at most once
+// per settings flow.
+func (s *authServiceKratos) RevealSettingsLookupSecret(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.UpdateSettingsLookupSecretForm,
+) (model.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+
+	if flowID != "" {
+		logger.Debug("sending get settings flow request to Kratos before revealing lookup secrets")
+		flow, _, err := s.kratosPublic.FrontendAPI.
+			GetSettingsFlow(ctx).
+			Cookie(util.ConcatCookies(cookies)).
+			Id(flowID).
+			Execute()
+
+		if err != nil {
+			logger.Error("failed to get settings flow", zap.String("flow_id", flowID), zap.Error(err))
+			return model.SettingsFlow{}, nil, handleKratosError(err)
+		}
+
+		if !hasInputNode(flow.Ui.GetNodes(), "lookup_secret_reveal") {
+			logger.Info("lookup secrets cannot be revealed on the flow", zap.String("flow_id", flowID))
+			return model.SettingsFlow{}, nil, fmt.Errorf("flow %s cannot reveal the backup codes: %w", flowID, response.ErrInvalidFlow)
+		}
+	}
+
+	reveal := true
+
+	flow, outCookies, err := s.updateSettingsLookupSecret(ctx, "reveal", flowID, cookies, form, kratos.UpdateSettingsFlowWithLookupMethod{
+		LookupSecretReveal: &reveal,
+	})
+
+	if err != nil {
+		return model.SettingsFlow{}, outCookies, err
+	}
+
+	return withLookupSecretCodes(flow), outCookies, nil
+}
+
+// withLookupSecretCodes converts a flow that just generated or revealed the
+// backup codes, with the codes.
+func withLookupSecretCodes(flow *kratos.SettingsFlow) model.SettingsFlow {
+	settings := toSettingsFlow(flow)
+
+	if settings.LookupSecret != nil {
+		settings.LookupSecret.Codes = findLookupSecretCodes(flow.Ui.GetNodes())
+	}
+
+	return settings
+}
+
+// ConfirmSettingsLookupSecret confirms that the user saved the generated
+// backup codes, which replaces the previous codes.
+func (s *authServiceKratos) ConfirmSettingsLookupSecret(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.UpdateSettingsLookupSecretForm,
+) (model.SettingsFlow, []*http.Cookie, error) {
+	confirm := true
+
+	flow, outCookies, err := s.updateSettingsLookupSecret(ctx, "confirm", flowID, cookies, form, kratos.UpdateSettingsFlowWithLookupMethod{
+		LookupSecretConfirm: &confirm,
+	})
+
+	if err != nil {
+		return model.SettingsFlow{}, outCookies, err
+	}
+
+	return toSettingsFlow(flow), outCookies, nil
+}
+
+func (s *authServiceKratos) updateSettingsLookupSecret(
+	ctx context.Context,
+	action string,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.UpdateSettingsLookupSecretForm,
+	body kratos.UpdateSettingsFlowWithLookupMethod,
+) (*kratos.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("updating lookup secret",
+		zap.String("action", action),
+		zap.String("flow_id", flowID),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for update lookup secret", zap.Any("errors", validationErrors))
+		return nil, nil, response.NewValidation(validationErrors)
+	}
+
+	body.Method = "lookup_secret"
+	body.CsrfToken = &form.CsrfToken
+
+	flow, outCookies, err := s.updateSettingsFlow(ctx, flowID, cookies, kratos.UpdateSettingsFlowBody{
+		UpdateSettingsFlowWithLookupMethod: &body,
+	})
+
+	if err != nil {
+		return nil, outCookies, err
+	}
+
+	logger.Info("lookup secret updated", zap.String("action", action), zap.String("flow_id", flow.Id))
+
+	return flow, outCookies, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+)
+
+// kratosSettingsFlow builds the JSON of a Kratos settings flow, which also
+// requires the identity.
+func kratosSettingsFlow(id string, nodes ...map[string]any) map[string]any {
+	flow := kratosFlow(id, "browser", "show_form", nil, nodes...)
+	flow["identity"] = map[string]any{
+		"id":         "identity",
+		"schema_id":  "default",
+		"schema_url": "http://kratos/schemas/default",
+		"traits":     map[string]any{"email": "user@example.com"},
+	}
+
+	return flow
+}
+
+func kratosLookupSecretNode(name string) map[string]any {
+	node := kratosInputNode(name, "true")
+	node["group"] = "lookup_secret"
+
+	return node
+}
+
+func kratosLookupSecretCodesNode(secrets ...map[string]any) map[string]any {
+	return map[string]any{
+		"type":     "text",
+		"group":    "lookup_secret",
+		"messages": []map[string]any{},
+		"meta":     map[string]any{},
+		"attributes": map[string]any{
+			"node_type": "text",
+			"id":        "lookup_secret_codes",
+			"text": map[string]any{
+				"id":      1050015,
+				"text":    "Backup recovery codes",
+				"type":    "info",
+				"context": map[string]any{"secrets": secrets},
+			},
+		},
+	}
+}
+
+func TestRevealSettingsLookupSecretOncePerFlow(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodes      []map[string]any
+		want       error
+		wantReveal bool
+	}{
+		{
+			name: "reveal offered",
+			nodes: []map[string]any{
+				kratosInputNode("csrf_token", "csrf"),
+				kratosLookupSecretNode("lookup_secret_reveal"),
+				kratosLookupSecretNode("lookup_secret_disable"),
+			},
+			wantReveal: true,
+		},
+		{
+			name: "already revealed",
+			nodes: []map[string]any{
+				kratosInputNode("csrf_token", "csrf"),
+				kratosLookupSecretCodesNode(),
+				kratosLookupSecretNode("lookup_secret_disable"),
+			},
+			want: response.ErrInvalidFlow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revealed := false
+			mux := http.NewServeMux()
+
+			mux.HandleFunc("GET /self-service/settings/flows", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, http.StatusOK, kratosSettingsFlow("settings-flow", tt.nodes...))
+			})
+
+			mux.HandleFunc("POST /self-service/settings", func(w http.ResponseWriter, r *http.Request) {
+				revealed = true
+
+				writeJSON(t, w, http.StatusOK, kratosSettingsFlow("settings-flow",
+					kratosInputNode("csrf_token", "csrf"),
+					kratosLookupSecretCodesNode(
+						map[string]any{"id": 1050009, "text": "abcd1234", "type": "info", "context": map[string]any{"secret": "abcd1234"}},
+						map[string]any{"id": 1050014, "text": "Used", "type": "info", "context": map[string]any{"used_at": "2026-01-01T00:00:00Z"}},
+					),
+					kratosLookupSecretNode("lookup_secret_disable"),
+				))
+			})
+
+			s := newKratosTestService(t, mux)
+
+			flow, _, err := s.RevealSettingsLookupSecret(context.Background(), "settings-flow", nil, &model.UpdateSettingsLookupSecretForm{
+				CsrfToken: "csrf",
+			})
+
+			if revealed != tt.wantReveal {
+				t.Errorf("revealed = %t, want %t", revealed, tt.wantReveal)
+			}
+
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Errorf("RevealSettingsLookupSecret() error = %v, want %v", err, tt.want)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("RevealSettingsLookupSecret() error = %v", err)
+			}
+
+			if flow.LookupSecret == nil {
+				t.Fatal("RevealSettingsLookupSecret() lookup_secret = nil")
+			}
+
+			var codes []string
+			for _, code := range flow.LookupSecret.Codes {
+				codes = append(codes, code.Code)
+			}
+
+			if want := []string{"abcd1234", ""}; !reflect.DeepEqual(codes, want) {
+				t.Errorf("RevealSettingsLookupSecret() codes = %q, want %q", codes, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	kratos "github.com/ory/kratos-client-go"
+	"go.uber.org/zap"
+)
+
+// findProvidersInNodes returns the providers of the "provider" buttons
+// Kratos renders for every configured social sign-in provider.
+func findProvidersInNodes(nodes []kratos.UiNode) []string {
+	var providers []string
+
+	for _, node := range nodes {
+		if node.Group != "oidc" {
+			continue
+		}
+
+		if attributes := inputAttributes(node); attributes != nil && attributes.Name == "provider" {
+			if provider, ok := attributes.Value.(string); ok {
+				providers = append(providers, provider)
+			}
+		}
+	}
+
+	return providers
+}
+
+// StartLoginOIDC starts a social sign-in on the login flow and returns the
+// URL of the provider. Kratos registers unknown users on the way back.
+func (s *authServiceKratos) StartLoginOIDC(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.StartLoginOIDCForm,
+) (model.StartLoginOIDCResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("starting oidc login",
+		zap.String("flow_id", flowID),
+		zap.String("provider", form.Provider),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.Provider == "" {
+		validationErrors["provider"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for start oidc login", zap.Any("errors", validationErrors))
+		return model.StartLoginOIDCResponse{}, nil, response.NewValidation(validationErrors)
+	}
+
+	logger.Debug("sending update login flow request to Kratos for oidc")
+	_, res, err := s.kratosPublic.FrontendAPI.UpdateLoginFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).UpdateLoginFlowBody(kratos.UpdateLoginFlowBody{
+		UpdateLoginFlowWithOidcMethod: &kratos.UpdateLoginFlowWithOidcMethod{
+			Method:    "oidc",
+			Provider:  form.Provider,
+			CsrfToken: &form.CsrfToken,
+		},
+	}).Execute()
+
+	if err == nil {
+		logger.Error("login flow did not redirect to the provider", zap.String("flow_id", flowID))
+		return model.StartLoginOIDCResponse{}, res.Cookies(), response.ErrInternal
+	}
+
+	openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+	if !ok {
+		return model.StartLoginOIDCResponse{}, nil, err
+	}
+
+	// Kratos answers browser flows that ask for JSON with a 422 and the
+	// URL to send the browser to.
+	if locationChange, ok := openApiErr.Model().(kratos.ErrorBrowserLocationChangeRequired); ok && locationChange.GetRedirectBrowserTo() != "" {
+		logger.Info("redirecting to oidc provider", zap.String("flow_id", flowID), zap.String("provider", form.Provider))
+		return model.StartLoginOIDCResponse{RedirectTo: locationChange.GetRedirectBrowserTo()}, res.Cookies(), nil
+	}
+
+	handledErr := handleKratosOpenAPIError(openApiErr)
+	logger.Error("handled Kratos OpenAPI error for oidc login", zap.Error(err), zap.Error(handledErr))
+	return model.StartLoginOIDCResponse{}, res.Cookies(), handledErr
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	kratos "github.com/ory/kratos-client-go"
+	"go.uber.org/zap"
+)
+
+// findJSONInNodes returns the value of a hidden input that holds JSON, like
+// the WebAuthn options Kratos renders for its JavaScript.
+func findJSONInNodes(nodes []kratos.UiNode, name string) json.RawMessage {
+	value := findInputValueInNodes(nodes, name)
+
+	if value == "" || !json.Valid([]byte(value)) {
+		return nil
+	}
+
+	return json.RawMessage(value)
+}
+
+// toPasskeySettings reads the passkey nodes of a settings flow: the options
+// to register a new passkey and one remove button per registered passkey.
+func toPasskeySettings(nodes []kratos.UiNode) *model.PasskeySettings {
+	var passkeys *model.PasskeySettings
+
+	for _, node := range nodes {
+		if node.Group != "passkey" {
+			continue
+		}
+
+		if passkeys == nil {
+			passkeys = &model.PasskeySettings{Credentials: []model.PasskeyCredential{}}
+		}
+
+		attributes := inputAttributes(node)
+		if attributes == nil || attributes.Name != "passkey_remove" {
+			continue
+		}
+
+		credential := model.PasskeyCredential{}
+		credential.ID, _ = attributes.Value.(string)
+
+		// The label of the button is "Remove passkey ..." with the
+		// details in its context.
+		if label := node.Meta.Label; label != nil {
+			credential.DisplayName, _ = label.Context["display_name"].(string)
+
+			if addedAt, ok := label.Context["added_at"].(string); ok {
+				if t, err := time.Parse(time.RFC3339, addedAt); err == nil {
+					credential.AddedAt = &t
+				}
+			}
+		}
+
+		passkeys.Credentials = append(passkeys.Credentials, credential)
+	}
+
+	if passkeys != nil {
+		// The create data wraps the options with the display name Kratos
+		// suggests for the new passkey.
+		var createData struct {
+			CredentialOptions json.RawMessage `json:"credentialOptions"`
+		}
+
+		if raw := findJSONInNodes(nodes, "passkey_create_data"); raw != nil && json.Unmarshal(raw, &createData) == nil {
+			passkeys.CreateOptions = createData.CredentialOptions
+		}
+	}
+
+	return passkeys
+}
+
+// SubmitLoginPasskey signs the user in with the credential the browser
+// returned for the passkey options of the login flow.
+func (s *authServiceKratos) SubmitLoginPasskey(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SubmitLoginPasskeyForm,
+) (model.SubmitLoginResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("submitting login passkey",
+		zap.String("flow_id", flowID),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := loginSubmitValidation(ctx, flowID, form.CsrfToken)
+
+	if len(form.Credential) == 0 {
+		validationErrors["credential"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for submit login passkey", zap.Any("errors", validationErrors))
+		return model.SubmitLoginResponse{}, nil, response.NewValidation(validationErrors)
+	}
+
+	credential := string(form.Credential)
+
+	return s.submitLoginFlow(ctx, flowID, cookies, kratos.UpdateLoginFlowBody{
+		UpdateLoginFlowWithPasskeyMethod: &kratos.UpdateLoginFlowWithPasskeyMethod{
+			Method:       "passkey",
+			PasskeyLogin: &credential,
+			CsrfToken:    &form.CsrfToken,
+		},
+	})
+}
+
+// RegisterSettingsPasskey adds the passkey the browser created with the
+// options of the settings flow.
+func (s *authServiceKratos) RegisterSettingsPasskey(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.RegisterSettingsPasskeyForm,
+) (model.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("registering passkey", zap.String("flow_id", flowID), zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if len(form.Credential) == 0 {
+		validationErrors["credential"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for register passkey", zap.Any("errors", validationErrors))
+		return model.SettingsFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	credential := string(form.Credential)
+
+	flow, outCookies, err := s.updateSettingsFlow(ctx, flowID, cookies, kratos.UpdateSettingsFlowBody{
+		UpdateSettingsFlowWithPasskeyMethod: &kratos.UpdateSettingsFlowWithPasskeyMethod{
+			Method:                  "passkey",
+			PasskeySettingsRegister: &credential,
+			CsrfToken:               &form.CsrfToken,
+		},
+	})
+
+	if err != nil {
+		return model.SettingsFlow{}, outCookies, err
+	}
+
+	logger.Info("passkey registered", zap.String("flow_id", flow.Id))
+
+	return toSettingsFlow(flow), outCookies, nil
+}
+
+// RemoveSettingsPasskey removes a registered passkey.
+func (s *authServiceKratos) RemoveSettingsPasskey(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.RemoveSettingsPasskeyForm,
+) (model.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("removing passkey", zap.String("flow_id", flowID), zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.ID == "" {
+		validationErrors["id"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for remove passkey", zap.Any("errors", validationErrors))
+		return model.SettingsFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	flow, outCookies, err := s.updateSettingsFlow(ctx, flowID, cookies, kratos.UpdateSettingsFlowBody{
+		UpdateSettingsFlowWithPasskeyMethod: &kratos.UpdateSettingsFlowWithPasskeyMethod{
+			Method:        "passkey",
+			PasskeyRemove: &form.ID,
+			CsrfToken:     &form.CsrfToken,
+		},
+	})
+
+	if err != nil {
+		return model.SettingsFlow{}, outCookies, err
+	}
+
+	logger.Info("passkey removed", zap.String("flow_id", flow.Id))
+
+	return toSettingsFlow(flow), outCookies, nil
+}
@@ -0,0 +1,449 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	kratos "github.com/ory/kratos-client-go"
+	"go.uber.org/zap"
+)
+
+func toRecoveryFlow(flow *kratos.RecoveryFlow) model.RecoveryFlow {
+	return model.RecoveryFlow{
+		ID:        flow.Id,
+		CsrfToken: findCsrfInNodes(flow.Ui.GetNodes()),
+		Email:     findInputValueInNodes(flow.Ui.GetNodes(), "email"),
+		FlowUI:    toFlowUI(flow.Ui, flow.State, &flow.ExpiresAt),
+	}
+}
+
+func toVerificationFlow(flow *kratos.VerificationFlow) model.VerificationFlow {
+	state, _ := flow.State.(string)
+
+	return model.VerificationFlow{
+		ID:        flow.Id,
+		CsrfToken: findCsrfInNodes(flow.Ui.GetNodes()),
+		Email:     findInputValueInNodes(flow.Ui.GetNodes(), "email"),
+		Verified:  state == "passed_challenge",
+		FlowUI:    toFlowUI(flow.Ui, flow.State, flow.ExpiresAt),
+	}
+}
+
+// settingsFlowFromContinueWith returns the settings flow Kratos wants the
+// user to continue with after a successful recovery.
+func settingsFlowFromContinueWith(continueWith []kratos.ContinueWith) string {
+	for _, item := range continueWith {
+		if item.ContinueWithSettingsUi != nil {
+			return item.ContinueWithSettingsUi.Flow.Id
+		}
+	}
+
+	return ""
+}
+
+// settingsFlowFromRedirect reads the flow ID from the settings UI URL that
+// Kratos sends browsers to after a successful recovery.
+func settingsFlowFromRedirect(redirectTo string) string {
+	redirectURL, err := url.Parse(redirectTo)
+	if err != nil {
+		return ""
+	}
+
+	return redirectURL.Query().Get("flow")
+}
+
+func (s *authServiceKratos) CreateRecoveryFlow(ctx context.Context) (model.RecoveryFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("creating recovery flow")
+
+	logger.Debug("sending create browser recovery flow request to Kratos")
+	flow, res, err := s.kratosPublic.FrontendAPI.CreateBrowserRecoveryFlow(ctx).Execute()
+
+	if err != nil {
+		logger.Error("failed to create recovery flow", zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.RecoveryFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.RecoveryFlow{}, nil, handledErr
+	}
+
+	logger.Info("recovery flow created successfully",
+		zap.String("flow_id", flow.Id),
+		zap.Int("response_cookies_count", len(res.Cookies())))
+
+	return toRecoveryFlow(flow), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) GetRecoveryFlow(ctx context.Context, id string, cookies []*http.Cookie) (model.RecoveryFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting recovery flow", zap.String("flow_id", id), zap.Int("cookies_count", len(cookies)))
+
+	if id == "" {
+		logger.Error("flow ID is required")
+		return model.RecoveryFlow{}, nil, response.NewValidation(map[string]string{"id": "required"})
+	}
+
+	logger.Debug("sending get recovery flow request to Kratos")
+	flow, res, err := s.kratosPublic.FrontendAPI.
+		GetRecoveryFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Id(id).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to get recovery flow", zap.String("flow_id", id), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.RecoveryFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.RecoveryFlow{}, nil, handledErr
+	}
+
+	logger.Info("recovery flow retrieved successfully", zap.String("flow_id", flow.Id))
+
+	return toRecoveryFlow(flow), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) SendRecoveryCode(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SendRecoveryCodeForm,
+) (model.RecoveryFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("sending recovery code",
+		zap.String("flow_id", flowID),
+		zap.String("email", form.Email),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.Email == "" {
+		validationErrors["email"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for send recovery code", zap.Any("errors", validationErrors))
+		return model.RecoveryFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	logger.Debug("sending update recovery flow request to Kratos for email code")
+	flow, res, err := s.kratosPublic.FrontendAPI.UpdateRecoveryFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).UpdateRecoveryFlowBody(kratos.UpdateRecoveryFlowBody{
+		UpdateRecoveryFlowWithCodeMethod: &kratos.UpdateRecoveryFlowWithCodeMethod{
+			Method:    "code",
+			Email:     &form.Email,
+			CsrfToken: &form.CsrfToken,
+		},
+	}).Execute()
+
+	if err != nil {
+		logger.Error("failed to send recovery code", zap.String("flow_id", flowID), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.RecoveryFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.RecoveryFlow{}, res.Cookies(), handledErr
+	}
+
+	// Kratos does not tell whether the address belongs to an account, the
+	// flow moves to sent_email either way.
+	logger.Info("recovery code sent", zap.String("flow_id", flow.Id), zap.Any("state", flow.State))
+
+	return toRecoveryFlow(flow), res.Cookies(), nil
+}
+
+// SubmitRecoveryCode checks the recovery code. On success Kratos signs the
+// user in and hands over to a settings flow.
+func (s *authServiceKratos) SubmitRecoveryCode(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SubmitRecoveryCodeForm,
+) (model.SubmitRecoveryCodeResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("submitting recovery code",
+		zap.String("flow_id", flowID),
+		zap.Bool("has_code", form.Code != ""),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.Code == "" {
+		validationErrors["code"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for submit recovery code", zap.Any("errors", validationErrors))
+		return model.SubmitRecoveryCodeResponse{}, nil, response.NewValidation(validationErrors)
+	}
+
+	logger.Debug("sending update recovery flow request to Kratos for code verification")
+	flow, res, err := s.kratosPublic.FrontendAPI.UpdateRecoveryFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).UpdateRecoveryFlowBody(kratos.UpdateRecoveryFlowBody{
+		UpdateRecoveryFlowWithCodeMethod: &kratos.UpdateRecoveryFlowWithCodeMethod{
+			Method:    "code",
+			Code:      &form.Code,
+			CsrfToken: &form.CsrfToken,
+		},
+	}).Execute()
+
+	if err != nil {
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			logger.Error("failed to submit recovery code", zap.String("flow_id", flowID), zap.Error(err))
+			return model.SubmitRecoveryCodeResponse{}, nil, err
+		}
+
+		// Browser flows finish with a redirect to the settings UI.
+		if redirectTo, ok := ory.UnpackKratosRedirectBrowserTo(openApiErr); ok {
+			if settingsFlowID := settingsFlowFromRedirect(redirectTo); settingsFlowID != "" {
+				logger.Info("recovery code accepted", zap.String("flow_id", flowID), zap.String("settings_flow_id", settingsFlowID))
+				return model.SubmitRecoveryCodeResponse{SettingsFlowID: settingsFlowID}, res.Cookies(), nil
+			}
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error for recovery code submission", zap.Error(err), zap.Error(handledErr))
+		return model.SubmitRecoveryCodeResponse{}, res.Cookies(), handledErr
+	}
+
+	if settingsFlowID := settingsFlowFromContinueWith(flow.ContinueWith); settingsFlowID != "" {
+		logger.Info("recovery code accepted", zap.String("flow_id", flowID), zap.String("settings_flow_id", settingsFlowID))
+		return model.SubmitRecoveryCodeResponse{SettingsFlowID: settingsFlowID}, res.Cookies(), nil
+	}
+
+	// A wrong code keeps the flow in sent_email with an error message.
+	logger.Error("recovery code rejected", zap.String("flow_id", flowID), zap.Any("state", flow.State))
+	return model.SubmitRecoveryCodeResponse{}, res.Cookies(), flowUiError(flow.Id, flow.Ui)
+}
+
+func (s *authServiceKratos) CreateVerificationFlow(ctx context.Context) (model.VerificationFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("creating verification flow")
+
+	logger.Debug("sending create browser verification flow request to Kratos")
+	flow, res, err := s.kratosPublic.FrontendAPI.CreateBrowserVerificationFlow(ctx).Execute()
+
+	if err != nil {
+		logger.Error("failed to create verification flow", zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.VerificationFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.VerificationFlow{}, nil, handledErr
+	}
+
+	logger.Info("verification flow created successfully",
+		zap.String("flow_id", flow.Id),
+		zap.Int("response_cookies_count", len(res.Cookies())))
+
+	return toVerificationFlow(flow), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) GetVerificationFlow(ctx context.Context, id string, cookies []*http.Cookie) (model.VerificationFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting verification flow", zap.String("flow_id", id), zap.Int("cookies_count", len(cookies)))
+
+	if id == "" {
+		logger.Error("flow ID is required")
+		return model.VerificationFlow{}, nil, response.NewValidation(map[string]string{"id": "required"})
+	}
+
+	logger.Debug("sending get verification flow request to Kratos")
+	flow, res, err := s.kratosPublic.FrontendAPI.
+		GetVerificationFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Id(id).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to get verification flow", zap.String("flow_id", id), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.VerificationFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.VerificationFlow{}, nil, handledErr
+	}
+
+	logger.Info("verification flow retrieved successfully", zap.String("flow_id", flow.Id))
+
+	return toVerificationFlow(flow), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) SendVerificationCode(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SendVerificationCodeForm,
+) (model.VerificationFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("sending verification code",
+		zap.String("flow_id", flowID),
+		zap.String("email", form.Email),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.Email == "" {
+		validationErrors["email"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for send verification code", zap.Any("errors", validationErrors))
+		return model.VerificationFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	logger.Debug("sending update verification flow request to Kratos for email code")
+	flow, res, err := s.kratosPublic.FrontendAPI.UpdateVerificationFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).UpdateVerificationFlowBody(kratos.UpdateVerificationFlowBody{
+		UpdateVerificationFlowWithCodeMethod: &kratos.UpdateVerificationFlowWithCodeMethod{
+			Method:    "code",
+			Email:     &form.Email,
+			CsrfToken: &form.CsrfToken,
+		},
+	}).Execute()
+
+	if err != nil {
+		logger.Error("failed to send verification code", zap.String("flow_id", flowID), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.VerificationFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.VerificationFlow{}, res.Cookies(), handledErr
+	}
+
+	logger.Info("verification code sent", zap.String("flow_id", flow.Id), zap.Any("state", flow.State))
+
+	return toVerificationFlow(flow), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) SubmitVerificationCode(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.SubmitVerificationCodeForm,
+) (model.VerificationFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("submitting verification code",
+		zap.String("flow_id", flowID),
+		zap.Bool("has_code", form.Code != ""),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if form.Code == "" {
+		validationErrors["code"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for submit verification code", zap.Any("errors", validationErrors))
+		return model.VerificationFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	logger.Debug("sending update verification flow request to Kratos for code verification")
+	flow, res, err := s.kratosPublic.FrontendAPI.UpdateVerificationFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).UpdateVerificationFlowBody(kratos.UpdateVerificationFlowBody{
+		UpdateVerificationFlowWithCodeMethod: &kratos.UpdateVerificationFlowWithCodeMethod{
+			Method:    "code",
+			Code:      &form.Code,
+			CsrfToken: &form.CsrfToken,
+		},
+	}).Execute()
+
+	if err != nil {
+		logger.Error("failed to submit verification code", zap.String("flow_id", flowID), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.VerificationFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error for verification code submission", zap.Error(err), zap.Error(handledErr))
+		return model.VerificationFlow{}, res.Cookies(), handledErr
+	}
+
+	verification := toVerificationFlow(flow)
+
+	if !verification.Verified {
+		logger.Error("verification code rejected", zap.String("flow_id", flowID), zap.Any("state", flow.State))
+		return model.VerificationFlow{}, res.Cookies(), flowUiError(flow.Id, flow.Ui)
+	}
+
+	logger.Info("email address verified", zap.String("flow_id", flowID))
+
+	return verification, res.Cookies(), nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+)
+
+func TestSubmitRecoveryCodeHandsOverToSettings(t *testing.T) {
+	passedFlow := kratosFlow("recovery-flow", "browser", "passed_challenge", nil)
+	passedFlow["continue_with"] = []map[string]any{{
+		"action": "show_settings_ui",
+		"flow":   map[string]any{"id": "settings-from-continue-with", "url": "http://ui/settings?flow=settings-from-continue-with"},
+	}}
+
+	tests := []struct {
+		name           string
+		status         int
+		body           any
+		wantSettingsID string
+		wantErr        bool
+	}{
+		{
+			name:   "browser location change",
+			status: http.StatusUnprocessableEntity,
+			body: map[string]any{
+				"error":               map[string]any{"id": "browser_location_change_required", "code": 422, "message": "browser location change required"},
+				"redirect_browser_to": "http://ui/settings?flow=settings-from-redirect",
+			},
+			wantSettingsID: "settings-from-redirect",
+		},
+		{
+			name:           "continue with settings ui",
+			status:         http.StatusOK,
+			body:           passedFlow,
+			wantSettingsID: "settings-from-continue-with",
+		},
+		{
+			name:   "wrong code",
+			status: http.StatusOK,
+			body: kratosFlow("recovery-flow", "browser", "sent_email",
+				[]map[string]any{kratosMessage(4060006, "The recovery code is invalid or has already been used. Please try again.")}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newKratosTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/self-service/recovery" {
+					http.NotFound(w, r)
+					return
+				}
+
+				http.SetCookie(w, &http.Cookie{Name: "ory_kratos_session", Value: "session"})
+				writeJSON(t, w, tt.status, tt.body)
+			}))
+
+			res, cookies, err := s.SubmitRecoveryCode(context.Background(), "recovery-flow", nil, &model.SubmitRecoveryCodeForm{
+				Code:      "123456",
+				CsrfToken: "csrf",
+			})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("SubmitRecoveryCode() = %+v, want error", res)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("SubmitRecoveryCode() error = %v", err)
+			}
+
+			if res.SettingsFlowID != tt.wantSettingsID {
+				t.Errorf("SubmitRecoveryCode() settings flow = %q, want %q", res.SettingsFlowID, tt.wantSettingsID)
+			}
+
+			if len(cookies) != 1 || cookies[0].Name != "ory_kratos_session" {
+				t.Errorf("SubmitRecoveryCode() cookies = %v, want the session cookie", cookies)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/util"
+	kratos "github.com/ory/kratos-client-go"
+	"go.uber.org/zap"
+)
+
+func toSettingsFlow(flow *kratos.SettingsFlow) model.SettingsFlow {
+	settings := model.SettingsFlow{
+		ID:           flow.Id,
+		CsrfToken:    findCsrfInNodes(flow.Ui.GetNodes()),
+		Traits:       toIdentity(&flow.Identity).Traits,
+		TOTP:         toTOTPSettings(flow.Ui.GetNodes()),
+		Passkeys:     toPasskeySettings(flow.Ui.GetNodes()),
+		LookupSecret: toLookupSecretSettings(flow.Ui.GetNodes()),
+		FlowUI:       toFlowUI(flow.Ui, flow.State, &flow.ExpiresAt),
+	}
+
+	for _, item := range flow.ContinueWith {
+		if item.ContinueWithVerificationUi != nil {
+			settings.VerificationFlowID = item.ContinueWithVerificationUi.Flow.Id
+		}
+	}
+
+	return settings
+}
+
+func (s *authServiceKratos) CreateSettingsFlow(ctx context.Context, cookies []*http.Cookie) (model.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("creating settings flow", zap.Int("cookies_count", len(cookies)))
+
+	logger.Debug("sending create browser settings flow request to Kratos")
+	flow, res, err := s.kratosPublic.FrontendAPI.
+		CreateBrowserSettingsFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to create settings flow", zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.SettingsFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.SettingsFlow{}, nil, handledErr
+	}
+
+	logger.Info("settings flow created successfully",
+		zap.String("flow_id", flow.Id),
+		zap.Int("response_cookies_count", len(res.Cookies())))
+
+	return toSettingsFlow(flow), res.Cookies(), nil
+}
+
+func (s *authServiceKratos) GetSettingsFlow(ctx context.Context, id string, cookies []*http.Cookie) (model.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting settings flow", zap.String("flow_id", id), zap.Int("cookies_count", len(cookies)))
+
+	if id == "" {
+		logger.Error("flow ID is required")
+		return model.SettingsFlow{}, nil, response.NewValidation(map[string]string{"id": "required"})
+	}
+
+	logger.Debug("sending get settings flow request to Kratos")
+	flow, res, err := s.kratosPublic.FrontendAPI.
+		GetSettingsFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Id(id).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to get settings flow", zap.String("flow_id", id), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return model.SettingsFlow{}, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return model.SettingsFlow{}, nil, handledErr
+	}
+
+	logger.Info("settings flow retrieved successfully", zap.String("flow_id", flow.Id))
+
+	return toSettingsFlow(flow), res.Cookies(), nil
+}
+
+// UpdateSettingsProfile updates the identity traits. Kratos sends a
+// verification code when the email address changes, and asks for a fresh
+// login (response.ErrSessionRefreshRequired) when the session is too old
+// for the change.
+func (s *authServiceKratos) UpdateSettingsProfile(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	form *model.UpdateSettingsProfileForm,
+) (model.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("updating settings profile",
+		zap.String("flow_id", flowID),
+		zap.Bool("has_csrf_token", form.CsrfToken != ""),
+		zap.Int("cookies_count", len(cookies)))
+
+	validationErrors := make(map[string]string)
+
+	if flowID == "" {
+		validationErrors["flow_id"] = "required"
+	}
+
+	if len(form.Traits) == 0 {
+		validationErrors["traits"] = "required"
+	}
+
+	if form.CsrfToken == "" {
+		validationErrors["csrf_token"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for update settings profile", zap.Any("errors", validationErrors))
+		return model.SettingsFlow{}, nil, response.NewValidation(validationErrors)
+	}
+
+	flow, outCookies, err := s.updateSettingsFlow(ctx, flowID, cookies, kratos.UpdateSettingsFlowBody{
+		UpdateSettingsFlowWithProfileMethod: &kratos.UpdateSettingsFlowWithProfileMethod{
+			Method:    "profile",
+			Traits:    form.Traits,
+			CsrfToken: &form.CsrfToken,
+		},
+	})
+
+	if err != nil {
+		return model.SettingsFlow{}, outCookies, err
+	}
+
+	settings := toSettingsFlow(flow)
+
+	logger.Info("settings profile updated",
+		zap.String("flow_id", flow.Id),
+		zap.Bool("verification_required", settings.VerificationFlowID != ""))
+
+	return settings, outCookies, nil
+}
+
+// updateSettingsFlow submits one settings method and translates the
+// errors, which carry the flow with messages when the input was invalid.
+func (s *authServiceKratos) updateSettingsFlow(
+	ctx context.Context,
+	flowID string,
+	cookies []*http.Cookie,
+	body kratos.UpdateSettingsFlowBody,
+) (*kratos.SettingsFlow, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+
+	logger.Debug("sending update settings flow request to Kratos", zap.String("flow_id", flowID))
+	flow, res, err := s.kratosPublic.FrontendAPI.UpdateSettingsFlow(ctx).
+		Cookie(util.ConcatCookies(cookies)).
+		Flow(flowID).
+		UpdateSettingsFlowBody(body).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to update settings flow", zap.String("flow_id", flowID), zap.Error(err))
+		openApiErr, ok := ory.UnpackKratosGenericOpenApiError(err)
+
+		if !ok {
+			return nil, nil, err
+		}
+
+		handledErr := handleKratosOpenAPIError(openApiErr)
+		logger.Error("handled Kratos OpenAPI error", zap.Error(err), zap.Error(handledErr))
+		return nil, res.Cookies(), handledErr
+	}
+
+	return flow, res.Cookies(), nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	kratos "github.com/ory/kratos-client-go"
+)
+
+func inputAttributes(node kratos.UiNode) *kratos.UiNodeInputAttributes {
+	if node.Type != "input" {
+		return nil
+	}
+
+	return node.Attributes.UiNodeInputAttributes
+}
+
+func findInputValueInNodes(nodes []kratos.UiNode, name string) string {
+	for _, node := range nodes {
+		if attributes := inputAttributes(node); attributes != nil && attributes.Name == name {
+			value, _ := attributes.Value.(string)
+			return value
+		}
+	}
+
+	return ""
+}
+
+// hasInputNode reports whether the nodes have an input, e.g. a button, with
+// the name.
+func hasInputNode(nodes []kratos.UiNode, name string) bool {
+	for _, node := range nodes {
+		if attributes := inputAttributes(node); attributes != nil && attributes.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findCsrfInNodes(nodes []kratos.UiNode) string {
+	return findInputValueInNodes(nodes, "csrf_token")
+}
+
+// findTraitsInNodes rebuilds the traits object from the "traits.*" input
+// nodes, e.g. "traits.name.first" becomes {"name": {"first": ...}}.
+func findTraitsInNodes(nodes []kratos.UiNode) map[string]any {
+	traits := make(map[string]any)
+
+	for _, node := range nodes {
+		attributes := inputAttributes(node)
+		if attributes == nil {
+			continue
+		}
+
+		path, ok := strings.CutPrefix(attributes.Name, "traits.")
+		if !ok || attributes.Value == nil {
+			continue
+		}
+
+		keys := strings.Split(path, ".")
+		current := traits
+
+		for _, key := range keys[:len(keys)-1] {
+			next, ok := current[key].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				current[key] = next
+			}
+			current = next
+		}
+
+		current[keys[len(keys)-1]] = attributes.Value
+	}
+
+	return traits
+}
+
+// findMethodsInNodes returns the authentication methods the flow offers,
+// which are the node groups except the shared ones.
+func findMethodsInNodes(nodes []kratos.UiNode) []string {
+	var methods []string
+
+	for _, node := range nodes {
+		switch node.Group {
+		case "default", "identifier_first", "captcha", "profile":
+			continue
+		}
+
+		if !slices.Contains(methods, node.Group) {
+			methods = append(methods, node.Group)
+		}
+	}
+
+	return methods
+}
+
+// hasUiMessage reports whether the flow or any of its nodes carries the
+// message.
+func hasUiMessage(ui kratos.UiContainer, id int64) bool {
+	for _, message := range ui.Messages {
+		if message.Id == id {
+			return true
+		}
+	}
+
+	for _, node := range ui.Nodes {
+		for _, message := range node.Messages {
+			if message.Id == id {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func toUIMessage(text kratos.UiText) model.UIMessage {
+	return model.UIMessage{
+		ID:      text.Id,
+		Type:    text.Type,
+		Text:    text.Text,
+		Context: text.Context,
+	}
+}
+
+// toFlowUI converts the parts of a Kratos flow every flow model shares.
+// Flow specific fields like the requested AAL are set by the caller.
+func toFlowUI(ui kratos.UiContainer, state any, expiresAt *time.Time) model.FlowUI {
+	flowUI := model.FlowUI{ExpiresAt: expiresAt}
+	flowUI.State, _ = state.(string)
+
+	for _, message := range ui.Messages {
+		flowUI.Messages = append(flowUI.Messages, toUIMessage(message))
+	}
+
+	for _, node := range ui.Nodes {
+		attributes := inputAttributes(node)
+		if attributes == nil {
+			continue
+		}
+
+		for _, message := range node.Messages {
+			if message.Type != "error" {
+				continue
+			}
+
+			if flowUI.FieldErrors == nil {
+				flowUI.FieldErrors = make(map[string][]model.UIMessage)
+			}
+
+			flowUI.FieldErrors[attributes.Name] = append(flowUI.FieldErrors[attributes.Name], toUIMessage(message))
+		}
+	}
+
+	return flowUI
+}
+
+// toFlowClient converts the OAuth2 client Kratos keeps on flows started
+// with a login challenge.
+func toFlowClient(loginRequest *kratos.OAuth2LoginRequest) *model.OAuth2Client {
+	if loginRequest == nil || loginRequest.Client == nil {
+		return nil
+	}
+
+	client := loginRequest.Client
+
+	return &model.OAuth2Client{
+		ID:        client.GetClientId(),
+		Name:      client.GetClientName(),
+		LogoURI:   client.GetLogoUri(),
+		ClientURI: client.GetClientUri(),
+		PolicyURI: client.GetPolicyUri(),
+		TosURI:    client.GetTosUri(),
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	kratos "github.com/ory/kratos-client-go"
+)
+
+func inputNode(name string, messages ...kratos.UiText) kratos.UiNode {
+	return kratos.UiNode{
+		Type:     "input",
+		Group:    "code",
+		Messages: messages,
+		Attributes: kratos.UiNodeAttributes{
+			UiNodeInputAttributes: &kratos.UiNodeInputAttributes{Name: name, NodeType: "input"},
+		},
+	}
+}
+
+func TestToFlowUI(t *testing.T) {
+	expiresAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	sent := kratos.UiText{Id: 1010014, Type: "info", Text: "An email containing a code has been sent to the email address you provided."}
+	invalidCode := kratos.UiText{Id: 4010008, Type: "error", Text: "The login code is invalid or has already been used. Please try again."}
+	missingEmail := kratos.UiText{
+		Id:      4000002,
+		Type:    "error",
+		Text:    "Property email is missing.",
+		Context: map[string]interface{}{"property": "email"},
+	}
+
+	tests := []struct {
+		name  string
+		ui    kratos.UiContainer
+		state any
+		want  model.FlowUI
+	}{
+		{
+			name:  "empty flow",
+			state: "choose_method",
+			want:  model.FlowUI{State: "choose_method", ExpiresAt: &expiresAt},
+		},
+		{
+			name:  "flow messages",
+			ui:    kratos.UiContainer{Messages: []kratos.UiText{sent}},
+			state: "sent_email",
+			want: model.FlowUI{
+				State:     "sent_email",
+				ExpiresAt: &expiresAt,
+				Messages:  []model.UIMessage{{ID: 1010014, Type: "info", Text: sent.Text}},
+			},
+		},
+		{
+			name: "field errors",
+			ui: kratos.UiContainer{Nodes: []kratos.UiNode{
+				inputNode("code", invalidCode, sent),
+				inputNode("traits.email", missingEmail),
+				inputNode("csrf_token"),
+			}},
+			state: "sent_email",
+			want: model.FlowUI{
+				State:     "sent_email",
+				ExpiresAt: &expiresAt,
+				FieldErrors: map[string][]model.UIMessage{
+					"code":         {{ID: 4010008, Type: "error", Text: invalidCode.Text}},
+					"traits.email": {{ID: 4000002, Type: "error", Text: missingEmail.Text, Context: map[string]any{"property": "email"}}},
+				},
+			},
+		},
+		{
+			name: "messages on non input nodes",
+			ui: kratos.UiContainer{Nodes: []kratos.UiNode{{
+				Type:     "text",
+				Messages: []kratos.UiText{invalidCode},
+				Attributes: kratos.UiNodeAttributes{
+					UiNodeTextAttributes: &kratos.UiNodeTextAttributes{NodeType: "text"},
+				},
+			}}},
+			want: model.FlowUI{ExpiresAt: &expiresAt},
+		},
+		{
+			name:  "state that is not a string",
+			state: map[string]any{"unexpected": true},
+			want:  model.FlowUI{ExpiresAt: &expiresAt},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toFlowUI(tt.ui, tt.state, &expiresAt)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toFlowUI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package service
+
+import "context"
+
+// private key to store/retrieve the native flow session token
+type ctxKeyNativeFlow struct{}
+
+// WithNativeFlow makes the IDP service use Kratos API flows for the request,
+// as apps without a cookie jar need: cookies and CSRF tokens are not used,
+// the session is read from sessionToken (empty when the app has none) and
+// new sessions are returned as session tokens.
+func WithNativeFlow(ctx context.Context, sessionToken string) context.Context {
+	return context.WithValue(ctx, ctxKeyNativeFlow{}, sessionToken)
+}
+
+// nativeFlow returns the session token of a request that uses API flows and
+// whether it does.
+func nativeFlow(ctx context.Context) (sessionToken string, native bool) {
+	sessionToken, native = ctx.Value(ctxKeyNativeFlow{}).(string)
+	return sessionToken, native
+}
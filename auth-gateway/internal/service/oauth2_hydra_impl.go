@@ -2,21 +2,145 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/config"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/middleware"
 	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
 	hydra "github.com/ory/hydra-client-go/v2"
+	"go.uber.org/zap"
 )
 
 type oauth2ServiceHydra struct {
 	hydraPublic *hydra.APIClient
 	hydraAdmin  *hydra.APIClient
+	loginConfig config.LoginConfig
 }
 
-func NewOAuth2ServiceHydra(hydraPublic *hydra.APIClient, hydraAdmin *hydra.APIClient) OAuth2Service {
-	return &oauth2ServiceHydra{hydraPublic: hydraPublic, hydraAdmin: hydraAdmin}
+func NewOAuth2ServiceHydra(hydraPublic *hydra.APIClient, hydraAdmin *hydra.APIClient, loginConfig config.LoginConfig) OAuth2Service {
+	return &oauth2ServiceHydra{hydraPublic: hydraPublic, hydraAdmin: hydraAdmin, loginConfig: loginConfig}
+}
+
+func handleHydraErrorCode(code int) error {
+	if code == http.StatusNotFound {
+		return fmt.Errorf("not found: %w", response.ErrNotFound)
+	}
+
+	// Hydra answers 410 Gone for challenges that were already handled.
+	if code == http.StatusGone {
+		return fmt.Errorf("challenge already handled: %w", response.ErrFlowExpired)
+	}
+
+	return nil
+}
+
+// handleHydraError maps Hydra admin API errors to transport errors and
+// returns the original error if there is no better match.
+func handleHydraError(err error, res *http.Response) error {
+	if res == nil {
+		return err
+	}
+
+	if oauth2Err, ok := unpackHydraOAuth2Error(err); ok && res.StatusCode == http.StatusBadRequest {
+		return response.NewValidation(map[string]string{
+			oauth2Err.GetError(): oauth2Err.GetErrorDescription(),
+		})
+	}
+
+	if e := handleHydraErrorCode(res.StatusCode); e != nil {
+		return e
+	}
+
+	return err
+}
+
+// unpackHydraOAuth2Error returns the OAuth2 error body of a failed Hydra
+// admin call, made through the client or directly like the device calls.
+func unpackHydraOAuth2Error(err error) (hydra.ErrorOAuth2, bool) {
+	if openApiErr, ok := ory.UnpackHydraGenericOpenApiError(err); ok {
+		oauth2Err, ok := openApiErr.Model().(hydra.ErrorOAuth2)
+		return oauth2Err, ok
+	}
+
+	var deviceErr *ory.DeviceAPIError
+	if errors.As(err, &deviceErr) {
+		return deviceErr.OAuth2Error()
+	}
+
+	return hydra.ErrorOAuth2{}, false
+}
+
+// newRejectOAuth2Request builds the Hydra reject body. An empty reason
+// defaults to access_denied.
+func newRejectOAuth2Request(reason model.OAuth2ErrorReason, description string) (*hydra.RejectOAuth2Request, error) {
+	if reason == "" {
+		reason = model.OAuth2ErrorAccessDenied
+	}
+
+	if !reason.Valid() {
+		return nil, response.NewValidation(map[string]string{"error": "unsupported"})
+	}
+
+	if description == "" {
+		description = "The resource owner denied the request"
+	}
+
+	body := hydra.NewRejectOAuth2Request()
+	body.SetError(string(reason))
+	body.SetErrorDescription(description)
+
+	return body, nil
+}
+
+// toOIDCContext combines Hydra's OIDC context with prompt and max_age,
+// which Hydra only keeps in the original request URL.
+func toOIDCContext(oidc *hydra.OAuth2ConsentRequestOpenIDConnectContext, requestURL string) model.OIDCContext {
+	var result model.OIDCContext
+
+	if oidc != nil {
+		result.LoginHint = oidc.GetLoginHint()
+		result.UILocales = oidc.UiLocales
+		result.ACRValues = oidc.AcrValues
+		result.Display = oidc.GetDisplay()
+	}
+
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		return result
+	}
+
+	query := parsedURL.Query()
+	result.Prompt = strings.Fields(query.Get("prompt"))
+
+	if maxAge, err := strconv.ParseInt(query.Get("max_age"), 10, 64); err == nil && maxAge >= 0 {
+		result.MaxAge = &maxAge
+	}
+
+	return result
+}
+
+func toOAuth2Client(client *hydra.OAuth2Client) model.OAuth2Client {
+	if client == nil {
+		return model.OAuth2Client{}
+	}
+
+	return model.OAuth2Client{
+		ID:        client.GetClientId(),
+		Name:      client.GetClientName(),
+		LogoURI:   client.GetLogoUri(),
+		ClientURI: client.GetClientUri(),
+		PolicyURI: client.GetPolicyUri(),
+		TosURI:    client.GetTosUri(),
+	}
 }
 
 func (o *oauth2ServiceHydra) GetOAuth2URL(query url.Values) string {
@@ -26,17 +150,526 @@ func (o *oauth2ServiceHydra) GetOAuth2URL(query url.Values) string {
 	return fmt.Sprintf("%s://%s/oauth2/auth?%s", cfg.Scheme, cfg.Host, query.Encode())
 }
 
+// supportedResponseTypes are the response_type values Hydra understands.
+var supportedResponseTypes = map[string]bool{"code": true, "token": true, "id_token": true}
+
+// ValidateAuthorizationRequest checks the authorization request against
+// the registered client, so misconfigured clients get a gateway error
+// instead of Hydra's error page.
+func (o *oauth2ServiceHydra) ValidateAuthorizationRequest(ctx context.Context, query url.Values) error {
+	logger := middleware.GetLoggerFrom(ctx)
+	clientID := query.Get("client_id")
+	logger.Info("validating authorization request", zap.String("client_id", clientID))
+
+	if clientID == "" {
+		return response.NewBadRequest("invalid_request", "The client_id parameter is missing")
+	}
+
+	client, res, err := o.hydraAdmin.OAuth2API.GetOAuth2Client(ctx, clientID).Execute()
+
+	if err != nil {
+		if res != nil && (res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusUnauthorized) {
+			logger.Info("unknown client", zap.String("client_id", clientID))
+			return response.NewBadRequest("invalid_client", "The client is not registered")
+		}
+
+		logger.Error("failed to get client", zap.String("client_id", clientID), zap.Error(err))
+		return handleHydraError(err, res)
+	}
+
+	redirectURI := query.Get("redirect_uri")
+
+	if redirectURI == "" && len(client.RedirectUris) != 1 {
+		return response.NewBadRequest("invalid_request", "The redirect_uri parameter is missing")
+	}
+
+	if redirectURI != "" && !slices.Contains(client.RedirectUris, redirectURI) {
+		logger.Info("redirect uri not registered", zap.String("client_id", clientID), zap.String("redirect_uri", redirectURI))
+		return response.NewBadRequest("invalid_redirect_uri", "The redirect_uri is not registered for this client")
+	}
+
+	responseTypes := strings.Fields(query.Get("response_type"))
+
+	if len(responseTypes) == 0 {
+		return response.NewBadRequest("invalid_request", "The response_type parameter is missing")
+	}
+
+	for _, responseType := range responseTypes {
+		if !supportedResponseTypes[responseType] {
+			return response.NewBadRequest("unsupported_response_type", "The response_type is not supported")
+		}
+	}
+
+	if !clientAllowsResponseType(client.ResponseTypes, responseTypes) {
+		return response.NewBadRequest("unsupported_response_type", "The response_type is not allowed for this client")
+	}
+
+	// Public clients cannot keep a secret, so they must use PKCE.
+	if client.GetTokenEndpointAuthMethod() == "none" {
+		if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+			return response.NewBadRequest("invalid_request", "Public clients must use PKCE with the S256 code challenge method")
+		}
+	}
+
+	return nil
+}
+
+// clientAllowsResponseType compares the requested response types with the
+// registered ones regardless of their order, e.g. "id_token code" matches
+// "code id_token".
+func clientAllowsResponseType(registered []string, requested []string) bool {
+	requested = slices.Clone(requested)
+	slices.Sort(requested)
+
+	for _, responseType := range registered {
+		allowed := strings.Fields(responseType)
+		slices.Sort(allowed)
+
+		if slices.Equal(allowed, requested) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *oauth2ServiceHydra) GetLoginRequest(ctx context.Context, challenge string) (model.LoginRequest, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting login request", zap.String("challenge", challenge))
+
+	if challenge == "" {
+		logger.Error("login challenge is required")
+		return model.LoginRequest{}, response.NewValidation(map[string]string{"challenge": "required"})
+	}
+
+	login, res, err := o.hydraAdmin.OAuth2API.GetOAuth2LoginRequest(ctx).
+		LoginChallenge(challenge).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to get login request", zap.String("challenge", challenge), zap.Error(err))
+		return model.LoginRequest{}, handleHydraError(err, res)
+	}
+
+	logger.Info("login request retrieved successfully",
+		zap.String("challenge", login.Challenge),
+		zap.Bool("skip", login.Skip))
+
+	return model.LoginRequest{
+		Challenge:      login.Challenge,
+		Subject:        login.Subject,
+		Skip:           login.Skip,
+		SessionID:      login.GetSessionId(),
+		RequestedScope: login.RequestedScope,
+		Client:         toOAuth2Client(&login.Client),
+		OIDC:           toOIDCContext(login.OidcContext, login.RequestUrl),
+	}, nil
+}
+
 func (o *oauth2ServiceHydra) AcceptOAuth2LoginChallenge(ctx context.Context, form *model.AcceptOAuth2LoginChallengeForm) (model.AcceptOAuth2LoginChallengeResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+
+	remember := o.loginConfig.Remember
+	if form.Remember != nil {
+		remember = *form.Remember
+	}
+
+	body := hydra.NewAcceptOAuth2LoginRequest(form.Subject)
+	body.SetRemember(remember)
+
+	if remember {
+		body.SetRememberFor(int64(o.loginConfig.RememberFor.Seconds()))
+	}
+
+	if form.ExtendSessionLifespan && o.loginConfig.ExtendSessionLifespan {
+		body.SetExtendSessionLifespan(true)
+	}
+
+	if form.ACR != "" {
+		body.SetAcr(form.ACR)
+	}
+
+	if len(form.AMR) > 0 {
+		body.SetAmr(form.AMR)
+	}
+
+	if form.SessionID != "" {
+		body.SetContext(map[string]string{"session_id": form.SessionID})
+		body.SetIdentityProviderSessionId(form.SessionID)
+	}
+
 	redirect, res, err := o.hydraAdmin.OAuth2API.AcceptOAuth2LoginRequest(ctx).
 		LoginChallenge(form.Challenge).
-		AcceptOAuth2LoginRequest(*hydra.NewAcceptOAuth2LoginRequest(form.Subject)).
+		AcceptOAuth2LoginRequest(*body).
 		Execute()
 
 	if err != nil {
-		return model.AcceptOAuth2LoginChallengeResponse{}, nil, err
+		logger.Error("failed to accept login challenge", zap.String("challenge", form.Challenge), zap.Error(err))
+		return model.AcceptOAuth2LoginChallengeResponse{}, nil, handleHydraError(err, res)
 	}
 
+	logger.Info("login challenge accepted",
+		zap.String("challenge", form.Challenge),
+		zap.Bool("remember", remember),
+		zap.String("acr", form.ACR))
+
 	return model.AcceptOAuth2LoginChallengeResponse{
 		RedirectTo: redirect.RedirectTo,
 	}, res.Cookies(), nil
 }
+
+func (o *oauth2ServiceHydra) RejectOAuth2LoginChallenge(ctx context.Context, form *model.RejectOAuth2LoginChallengeForm) (model.RejectOAuth2LoginChallengeResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("rejecting login challenge",
+		zap.String("challenge", form.Challenge),
+		zap.String("error", string(form.Error)))
+
+	if form.Challenge == "" {
+		logger.Error("login challenge is required")
+		return model.RejectOAuth2LoginChallengeResponse{}, nil, response.NewValidation(map[string]string{"login_challenge": "required"})
+	}
+
+	body, err := newRejectOAuth2Request(form.Error, form.ErrorDescription)
+	if err != nil {
+		logger.Error("invalid reject reason", zap.String("error", string(form.Error)))
+		return model.RejectOAuth2LoginChallengeResponse{}, nil, err
+	}
+
+	redirect, res, err := o.hydraAdmin.OAuth2API.RejectOAuth2LoginRequest(ctx).
+		LoginChallenge(form.Challenge).
+		RejectOAuth2Request(*body).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to reject login challenge", zap.String("challenge", form.Challenge), zap.Error(err))
+		return model.RejectOAuth2LoginChallengeResponse{}, nil, handleHydraError(err, res)
+	}
+
+	return model.RejectOAuth2LoginChallengeResponse{
+		RedirectTo: redirect.RedirectTo,
+	}, res.Cookies(), nil
+}
+
+func (o *oauth2ServiceHydra) GetConsentRequest(ctx context.Context, challenge string) (model.ConsentRequest, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting consent request", zap.String("challenge", challenge))
+
+	if challenge == "" {
+		logger.Error("consent challenge is required")
+		return model.ConsentRequest{}, response.NewValidation(map[string]string{"consent_challenge": "required"})
+	}
+
+	consent, res, err := o.hydraAdmin.OAuth2API.GetOAuth2ConsentRequest(ctx).
+		ConsentChallenge(challenge).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to get consent request", zap.String("challenge", challenge), zap.Error(err))
+		return model.ConsentRequest{}, handleHydraError(err, res)
+	}
+
+	logger.Info("consent request retrieved successfully",
+		zap.String("challenge", consent.Challenge),
+		zap.Bool("skip", consent.GetSkip()),
+		zap.Strings("requested_scope", consent.RequestedScope))
+
+	return model.ConsentRequest{
+		Challenge:         consent.Challenge,
+		Subject:           consent.GetSubject(),
+		Skip:              consent.GetSkip(),
+		RequestedScope:    consent.RequestedScope,
+		RequestedAudience: consent.RequestedAccessTokenAudience,
+		Client:            toOAuth2Client(consent.Client),
+	}, nil
+}
+
+func (o *oauth2ServiceHydra) AcceptConsentChallenge(ctx context.Context, form *model.AcceptOAuth2ConsentChallengeForm) (model.AcceptOAuth2ConsentChallengeResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("accepting consent challenge",
+		zap.String("challenge", form.Challenge),
+		zap.Strings("grant_scope", form.GrantScope),
+		zap.Bool("remember", form.Remember))
+
+	if form.Challenge == "" {
+		logger.Error("consent challenge is required")
+		return model.AcceptOAuth2ConsentChallengeResponse{}, nil, response.NewValidation(map[string]string{"consent_challenge": "required"})
+	}
+
+	body := hydra.NewAcceptOAuth2ConsentRequest()
+	body.SetGrantScope(form.GrantScope)
+	body.SetGrantAccessTokenAudience(form.GrantAudience)
+	body.SetRemember(form.Remember)
+
+	if len(form.IDTokenClaims) > 0 || len(form.AccessTokenClaims) > 0 {
+		session := hydra.NewAcceptOAuth2ConsentRequestSession()
+		session.SetIdToken(form.IDTokenClaims)
+		session.SetAccessToken(form.AccessTokenClaims)
+		body.SetSession(*session)
+	}
+
+	redirect, res, err := o.hydraAdmin.OAuth2API.AcceptOAuth2ConsentRequest(ctx).
+		ConsentChallenge(form.Challenge).
+		AcceptOAuth2ConsentRequest(*body).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to accept consent challenge", zap.String("challenge", form.Challenge), zap.Error(err))
+		return model.AcceptOAuth2ConsentChallengeResponse{}, nil, handleHydraError(err, res)
+	}
+
+	return model.AcceptOAuth2ConsentChallengeResponse{
+		RedirectTo: redirect.RedirectTo,
+	}, res.Cookies(), nil
+}
+
+func (o *oauth2ServiceHydra) RejectConsentChallenge(ctx context.Context, form *model.RejectOAuth2ConsentChallengeForm) (model.RejectOAuth2ConsentChallengeResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("rejecting consent challenge",
+		zap.String("challenge", form.Challenge),
+		zap.String("error", string(form.Error)))
+
+	if form.Challenge == "" {
+		logger.Error("consent challenge is required")
+		return model.RejectOAuth2ConsentChallengeResponse{}, nil, response.NewValidation(map[string]string{"consent_challenge": "required"})
+	}
+
+	body, err := newRejectOAuth2Request(form.Error, form.ErrorDescription)
+	if err != nil {
+		logger.Error("invalid reject reason", zap.String("error", string(form.Error)))
+		return model.RejectOAuth2ConsentChallengeResponse{}, nil, err
+	}
+
+	redirect, res, err := o.hydraAdmin.OAuth2API.RejectOAuth2ConsentRequest(ctx).
+		ConsentChallenge(form.Challenge).
+		RejectOAuth2Request(*body).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to reject consent challenge", zap.String("challenge", form.Challenge), zap.Error(err))
+		return model.RejectOAuth2ConsentChallengeResponse{}, nil, handleHydraError(err, res)
+	}
+
+	return model.RejectOAuth2ConsentChallengeResponse{
+		RedirectTo: redirect.RedirectTo,
+	}, res.Cookies(), nil
+}
+
+func (o *oauth2ServiceHydra) GetLogoutRequest(ctx context.Context, challenge string) (model.LogoutRequest, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("getting logout request", zap.String("challenge", challenge))
+
+	if challenge == "" {
+		logger.Error("logout challenge is required")
+		return model.LogoutRequest{}, response.NewValidation(map[string]string{"logout_challenge": "required"})
+	}
+
+	logout, res, err := o.hydraAdmin.OAuth2API.GetOAuth2LogoutRequest(ctx).
+		LogoutChallenge(challenge).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to get logout request", zap.String("challenge", challenge), zap.Error(err))
+		return model.LogoutRequest{}, handleHydraError(err, res)
+	}
+
+	return model.LogoutRequest{
+		Challenge:   logout.GetChallenge(),
+		Subject:     logout.GetSubject(),
+		SessionID:   logout.GetSid(),
+		RPInitiated: logout.GetRpInitiated(),
+		Client:      toOAuth2Client(logout.Client),
+	}, nil
+}
+
+func (o *oauth2ServiceHydra) AcceptLogoutChallenge(ctx context.Context, challenge string) (model.AcceptOAuth2LogoutChallengeResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("accepting logout challenge", zap.String("challenge", challenge))
+
+	if challenge == "" {
+		logger.Error("logout challenge is required")
+		return model.AcceptOAuth2LogoutChallengeResponse{}, nil, response.NewValidation(map[string]string{"logout_challenge": "required"})
+	}
+
+	redirect, res, err := o.hydraAdmin.OAuth2API.AcceptOAuth2LogoutRequest(ctx).
+		LogoutChallenge(challenge).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to accept logout challenge", zap.String("challenge", challenge), zap.Error(err))
+		return model.AcceptOAuth2LogoutChallengeResponse{}, nil, handleHydraError(err, res)
+	}
+
+	return model.AcceptOAuth2LogoutChallengeResponse{
+		RedirectTo: redirect.RedirectTo,
+	}, res.Cookies(), nil
+}
+
+func (o *oauth2ServiceHydra) RejectLogoutChallenge(ctx context.Context, challenge string) error {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("rejecting logout challenge", zap.String("challenge", challenge))
+
+	if challenge == "" {
+		logger.Error("logout challenge is required")
+		return response.NewValidation(map[string]string{"logout_challenge": "required"})
+	}
+
+	res, err := o.hydraAdmin.OAuth2API.RejectOAuth2LogoutRequest(ctx).
+		LogoutChallenge(challenge).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to reject logout challenge", zap.String("challenge", challenge), zap.Error(err))
+		return handleHydraError(err, res)
+	}
+
+	return nil
+}
+
+func (o *oauth2ServiceHydra) IntrospectToken(ctx context.Context, token string) (model.IntrospectedToken, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+
+	if token == "" {
+		return model.IntrospectedToken{}, response.NewValidation(map[string]string{"token": "required"})
+	}
+
+	introspected, res, err := o.hydraAdmin.OAuth2API.IntrospectOAuth2Token(ctx).
+		Token(token).
+		Execute()
+
+	if err != nil {
+		logger.Error("failed to introspect token", zap.Error(err))
+		return model.IntrospectedToken{}, handleHydraError(err, res)
+	}
+
+	result := model.IntrospectedToken{
+		Active:   introspected.Active,
+		Subject:  introspected.GetSub(),
+		ClientID: introspected.GetClientId(),
+		Scope:    introspected.GetScope(),
+		Audience: introspected.Aud,
+		TokenUse: introspected.GetTokenUse(),
+		Extra:    introspected.Ext,
+	}
+
+	if introspected.Exp != nil {
+		result.ExpiresAt = time.Unix(*introspected.Exp, 0)
+	}
+
+	logger.Debug("token introspected",
+		zap.Bool("active", result.Active),
+		zap.String("client_id", result.ClientID))
+
+	return result, nil
+}
+
+func (o *oauth2ServiceHydra) ListConsentSessions(ctx context.Context, subject string) ([]model.ConsentSession, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("listing consent sessions", zap.String("subject", subject))
+
+	if subject == "" {
+		return nil, response.NewValidation(map[string]string{"subject": "required"})
+	}
+
+	consentSessions := make([]model.ConsentSession, 0)
+	pageToken := ""
+
+	for {
+		req := o.hydraAdmin.OAuth2API.ListOAuth2ConsentSessions(ctx).Subject(subject)
+
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
+		}
+
+		sessions, res, err := req.Execute()
+
+		if err != nil {
+			logger.Error("failed to list consent sessions", zap.String("subject", subject), zap.Error(err))
+			return nil, handleHydraError(err, res)
+		}
+
+		for _, session := range sessions {
+			consentSession := model.ConsentSession{
+				GrantedScope:    session.GrantScope,
+				GrantedAudience: session.GrantAccessTokenAudience,
+				GrantedAt:       session.HandledAt,
+				Remember:        session.GetRemember(),
+			}
+
+			if session.ConsentRequest != nil {
+				consentSession.Client = toOAuth2Client(session.ConsentRequest.Client)
+			}
+
+			consentSessions = append(consentSessions, consentSession)
+		}
+
+		pageToken = nextPageToken(res)
+		if pageToken == "" || len(sessions) == 0 {
+			break
+		}
+	}
+
+	return consentSessions, nil
+}
+
+// RevokeConsentSessions revokes the consent the subject gave to the client,
+// or to all clients if clientID is empty. Hydra invalidates the tokens
+// issued under these consents as well.
+func (o *oauth2ServiceHydra) RevokeConsentSessions(ctx context.Context, subject string, clientID string) error {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("revoking consent sessions", zap.String("subject", subject), zap.String("client_id", clientID))
+
+	if subject == "" {
+		return response.NewValidation(map[string]string{"subject": "required"})
+	}
+
+	req := o.hydraAdmin.OAuth2API.RevokeOAuth2ConsentSessions(ctx).Subject(subject)
+
+	if clientID != "" {
+		req = req.Client(clientID)
+	} else {
+		req = req.All(true)
+	}
+
+	res, err := req.Execute()
+
+	if err != nil {
+		logger.Error("failed to revoke consent sessions", zap.String("subject", subject), zap.Error(err))
+		return handleHydraError(err, res)
+	}
+
+	return nil
+}
+
+// AcceptDeviceChallenge accepts the user code of a device authorization
+// grant. Hydra then continues with the regular login and consent
+// challenges, so the user signs in through the usual login flow.
+func (o *oauth2ServiceHydra) AcceptDeviceChallenge(ctx context.Context, form *model.AcceptOAuth2DeviceChallengeForm) (model.AcceptOAuth2DeviceChallengeResponse, []*http.Cookie, error) {
+	logger := middleware.GetLoggerFrom(ctx)
+	logger.Info("accepting device challenge", zap.String("challenge", form.Challenge))
+
+	validationErrors := make(map[string]string)
+
+	if form.Challenge == "" {
+		validationErrors["device_challenge"] = "required"
+	}
+
+	if form.UserCode == "" {
+		validationErrors["user_code"] = "required"
+	}
+
+	if len(validationErrors) > 0 {
+		logger.Error("validation failed for accept device challenge", zap.Any("errors", validationErrors))
+		return model.AcceptOAuth2DeviceChallengeResponse{}, nil, response.NewValidation(validationErrors)
+	}
+
+	redirect, res, err := ory.AcceptDeviceUserCode(ctx, o.hydraAdmin, form.Challenge, form.UserCode)
+
+	if err != nil {
+		logger.Error("failed to accept device challenge", zap.String("challenge", form.Challenge), zap.Error(err))
+		return model.AcceptOAuth2DeviceChallengeResponse{}, nil, handleHydraError(err, res)
+	}
+
+	return model.AcceptOAuth2DeviceChallengeResponse{
+		RedirectTo: redirect.RedirectTo,
+	}, res.Cookies(), nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/config"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/model"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/ory"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/response"
+)
+
+func TestAcceptDeviceChallengeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantErr     error
+		wantDetails map[string]string
+	}{
+		{
+			name:        "invalid user code",
+			status:      http.StatusBadRequest,
+			body:        `{"error":"invalid_request","error_description":"The user code is invalid"}`,
+			wantDetails: map[string]string{"invalid_request": "The user code is invalid"},
+		},
+		{
+			name:    "unknown challenge",
+			status:  http.StatusNotFound,
+			body:    `{"error":"not_found"}`,
+			wantErr: response.ErrNotFound,
+		},
+		{
+			name:    "challenge already handled",
+			status:  http.StatusGone,
+			body:    `{"redirect_to":"http://hydra/oauth2/device/verify"}`,
+			wantErr: response.ErrFlowExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hydraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer hydraServer.Close()
+
+			hydraAdmin, err := ory.NewHydraAdmin(hydraServer.URL, hydraServer.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			o := NewOAuth2ServiceHydra(nil, hydraAdmin, config.LoginConfig{})
+			_, _, err = o.AcceptDeviceChallenge(context.Background(), &model.AcceptOAuth2DeviceChallengeForm{
+				Challenge: "challenge",
+				UserCode:  "ABCD-EFGH",
+			})
+
+			if tt.wantDetails == nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("AcceptDeviceChallenge() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			var httpErr response.HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("AcceptDeviceChallenge() error = %v, want validation error", err)
+			}
+
+			if got := httpErr.Details(); !reflect.DeepEqual(got, tt.wantDetails) {
+				t.Errorf("AcceptDeviceChallenge() details = %v, want %v", got, tt.wantDetails)
+			}
+		})
+	}
+}
@@ -9,13 +9,64 @@ import (
 )
 
 type IDPService interface {
-	CreateLoginFlow(ctx context.Context, challenge string, cookies []*http.Cookie) (model.LoginFlow, []*http.Cookie, error)
+	CreateLoginFlow(ctx context.Context, challenge string, cookies []*http.Cookie, form *model.CreateLoginFlowForm) (model.LoginFlow, []*http.Cookie, error)
 	GetLoginFlow(ctx context.Context, flowID string, cookies []*http.Cookie) (model.LoginFlow, []*http.Cookie, error)
 	SendLoginEmailCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SendLoginEmailCodeForm) (model.LoginFlow, []*http.Cookie, error)
-	SubmitLoginEmailCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SubmitLoginEmailCodeForm) (model.SubmitLoginEmailCodeResponse, []*http.Cookie, error)
+	SubmitLoginEmailCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SubmitLoginEmailCodeForm) (model.SubmitLoginResponse, []*http.Cookie, error)
+	SubmitLoginTOTP(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SubmitLoginSecondFactorForm) (model.SubmitLoginResponse, []*http.Cookie, error)
+	SubmitLoginPasskey(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SubmitLoginPasskeyForm) (model.SubmitLoginResponse, []*http.Cookie, error)
+	StartLoginOIDC(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.StartLoginOIDCForm) (model.StartLoginOIDCResponse, []*http.Cookie, error)
+	SubmitLoginLookupSecret(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SubmitLoginSecondFactorForm) (model.SubmitLoginResponse, []*http.Cookie, error)
+	CreateRegistrationFlow(ctx context.Context, challenge string) (model.RegistrationFlow, []*http.Cookie, error)
+	GetRegistrationFlow(ctx context.Context, flowID string, cookies []*http.Cookie) (model.RegistrationFlow, []*http.Cookie, error)
+	SendRegistrationCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SendRegistrationCodeForm) (model.RegistrationFlow, []*http.Cookie, error)
+	SubmitRegistrationCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SubmitRegistrationCodeForm) (model.SubmitRegistrationCodeResponse, []*http.Cookie, error)
+	CreateRecoveryFlow(ctx context.Context) (model.RecoveryFlow, []*http.Cookie, error)
+	GetRecoveryFlow(ctx context.Context, flowID string, cookies []*http.Cookie) (model.RecoveryFlow, []*http.Cookie, error)
+	SendRecoveryCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SendRecoveryCodeForm) (model.RecoveryFlow, []*http.Cookie, error)
+	SubmitRecoveryCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SubmitRecoveryCodeForm) (model.SubmitRecoveryCodeResponse, []*http.Cookie, error)
+	CreateVerificationFlow(ctx context.Context) (model.VerificationFlow, []*http.Cookie, error)
+	GetVerificationFlow(ctx context.Context, flowID string, cookies []*http.Cookie) (model.VerificationFlow, []*http.Cookie, error)
+	SendVerificationCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SendVerificationCodeForm) (model.VerificationFlow, []*http.Cookie, error)
+	SubmitVerificationCode(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.SubmitVerificationCodeForm) (model.VerificationFlow, []*http.Cookie, error)
+	CreateSettingsFlow(ctx context.Context, cookies []*http.Cookie) (model.SettingsFlow, []*http.Cookie, error)
+	GetSettingsFlow(ctx context.Context, flowID string, cookies []*http.Cookie) (model.SettingsFlow, []*http.Cookie, error)
+	UpdateSettingsProfile(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.UpdateSettingsProfileForm) (model.SettingsFlow, []*http.Cookie, error)
+	LinkSettingsTOTP(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.LinkSettingsTOTPForm) (model.SettingsFlow, []*http.Cookie, error)
+	UnlinkSettingsTOTP(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.UnlinkSettingsTOTPForm) (model.SettingsFlow, []*http.Cookie, error)
+	RegisterSettingsPasskey(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.RegisterSettingsPasskeyForm) (model.SettingsFlow, []*http.Cookie, error)
+	RemoveSettingsPasskey(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.RemoveSettingsPasskeyForm) (model.SettingsFlow, []*http.Cookie, error)
+	GenerateSettingsLookupSecret(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.UpdateSettingsLookupSecretForm) (model.SettingsFlow, []*http.Cookie, error)
+	RevealSettingsLookupSecret(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.UpdateSettingsLookupSecretForm) (model.SettingsFlow, []*http.Cookie, error)
+	ConfirmSettingsLookupSecret(ctx context.Context, flowID string, cookies []*http.Cookie, form *model.UpdateSettingsLookupSecretForm) (model.SettingsFlow, []*http.Cookie, error)
+	ToSession(ctx context.Context, cookies []*http.Cookie) (model.Session, []*http.Cookie, error)
+	GetIdentity(ctx context.Context, id string) (model.Identity, error)
+	CreateLogoutFlow(ctx context.Context, cookies []*http.Cookie) (model.LogoutFlow, []*http.Cookie, error)
+	PerformLogout(ctx context.Context, token string, cookies []*http.Cookie) ([]*http.Cookie, error)
 }
 
 type OAuth2Service interface {
 	GetOAuth2URL(query url.Values) string
+	ValidateAuthorizationRequest(ctx context.Context, query url.Values) error
+	GetLoginRequest(ctx context.Context, challenge string) (model.LoginRequest, error)
 	AcceptOAuth2LoginChallenge(ctx context.Context, form *model.AcceptOAuth2LoginChallengeForm) (model.AcceptOAuth2LoginChallengeResponse, []*http.Cookie, error)
+	RejectOAuth2LoginChallenge(ctx context.Context, form *model.RejectOAuth2LoginChallengeForm) (model.RejectOAuth2LoginChallengeResponse, []*http.Cookie, error)
+	GetConsentRequest(ctx context.Context, challenge string) (model.ConsentRequest, error)
+	AcceptConsentChallenge(ctx context.Context, form *model.AcceptOAuth2ConsentChallengeForm) (model.AcceptOAuth2ConsentChallengeResponse, []*http.Cookie, error)
+	RejectConsentChallenge(ctx context.Context, form *model.RejectOAuth2ConsentChallengeForm) (model.RejectOAuth2ConsentChallengeResponse, []*http.Cookie, error)
+	GetLogoutRequest(ctx context.Context, challenge string) (model.LogoutRequest, error)
+	AcceptLogoutChallenge(ctx context.Context, challenge string) (model.AcceptOAuth2LogoutChallengeResponse, []*http.Cookie, error)
+	RejectLogoutChallenge(ctx context.Context, challenge string) error
+	AcceptDeviceChallenge(ctx context.Context, form *model.AcceptOAuth2DeviceChallengeForm) (model.AcceptOAuth2DeviceChallengeResponse, []*http.Cookie, error)
+	ListConsentSessions(ctx context.Context, subject string) ([]model.ConsentSession, error)
+	RevokeConsentSessions(ctx context.Context, subject string, clientID string) error
+	IntrospectToken(ctx context.Context, token string) (model.IntrospectedToken, error)
+}
+
+type ClientService interface {
+	ListClients(ctx context.Context, form *model.ListClientsForm) (model.ClientList, error)
+	GetClient(ctx context.Context, id string) (model.Client, error)
+	CreateClient(ctx context.Context, form *model.ClientForm) (model.Client, error)
+	UpdateClient(ctx context.Context, id string, form *model.ClientForm) (model.Client, error)
+	DeleteClient(ctx context.Context, id string) error
 }
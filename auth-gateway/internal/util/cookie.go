@@ -1,10 +1,13 @@
 package util
 
-import "net/http"
+import (
+	"net/http"
+	"slices"
+)
 
 func ForwardSetCookieHeader(cookies []*http.Cookie, w http.ResponseWriter) {
 	for _, cookie := range cookies {
-		w.Header().Set("Set-Cookie", cookie.String())
+		w.Header().Add("Set-Cookie", cookie.String())
 	}
 }
 
@@ -17,3 +20,17 @@ func ConcatCookies(cookies []*http.Cookie) string {
 
 	return concatString
 }
+
+// MergeCookies returns cookies with the values of updates applied, as a
+// browser would after receiving the updates as Set-Cookie headers.
+func MergeCookies(cookies []*http.Cookie, updates []*http.Cookie) []*http.Cookie {
+	merged := make([]*http.Cookie, 0, len(cookies)+len(updates))
+
+	for _, cookie := range cookies {
+		if !slices.ContainsFunc(updates, func(update *http.Cookie) bool { return update.Name == cookie.Name }) {
+			merged = append(merged, cookie)
+		}
+	}
+
+	return append(merged, updates...)
+}
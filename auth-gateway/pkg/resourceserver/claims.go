@@ -0,0 +1,37 @@
+package resourceserver
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Claims are the verified claims of an access token.
+type Claims struct {
+	Subject   string         `json:"sub"`
+	ClientID  string         `json:"client_id"`
+	Issuer    string         `json:"iss"`
+	Audience  []string       `json:"aud"`
+	Scopes    []string       `json:"scopes"`
+	ExpiresAt time.Time      `json:"exp"`
+	Extra     map[string]any `json:"ext,omitempty"`
+}
+
+// HasScope reports whether the token was granted the scope.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// private key to store/retrieve the claims
+type ctxKeyClaims struct{}
+
+// GetClaimsFrom returns the claims the middleware verified for the request.
+func GetClaimsFrom(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(ctxKeyClaims{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// WithClaims returns a copy of ctx that carries the claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, ctxKeyClaims{}, claims)
+}
@@ -0,0 +1,58 @@
+package resourceserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Middleware rejects requests without a valid bearer token, or whose token
+// lacks one of the required scopes. Verified claims are stored in the
+// request context, see GetClaimsFrom.
+func (v *Validator) Middleware(requiredScopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			token = strings.TrimSpace(token)
+
+			if !ok || token == "" {
+				writeChallenge(w, http.StatusUnauthorized, "", "")
+				return
+			}
+
+			claims, err := v.Validate(r.Context(), token)
+
+			switch {
+			case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrOpaqueTokenUnsupported):
+				writeChallenge(w, http.StatusUnauthorized, "invalid_token", "")
+				return
+			case err != nil:
+				http.Error(w, "token validation unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			for _, scope := range requiredScopes {
+				if !claims.HasScope(scope) {
+					writeChallenge(w, http.StatusForbidden, "insufficient_scope", strings.Join(requiredScopes, " "))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+func writeChallenge(w http.ResponseWriter, status int, errorCode string, scope string) {
+	challenge := "Bearer"
+	if errorCode != "" {
+		challenge += ` error="` + errorCode + `"`
+	}
+
+	if scope != "" {
+		challenge += `, scope="` + scope + `"`
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.WriteHeader(status)
+}
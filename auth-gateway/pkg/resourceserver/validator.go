@@ -0,0 +1,320 @@
+// Package resourceserver protects APIs behind the gateway with access
+// tokens issued by Hydra.
+//
+// JWT access tokens are verified locally against the issuer's JWKS, opaque
+// tokens are introspected through the Hydra admin API:
+//
+//	validator, err := resourceserver.NewValidator(resourceserver.Config{
+//		Issuer:           "http://auth.learny.local/hydra",
+//		Audience:         []string{"learny-api"},
+//		IntrospectionURL: "http://hydra:4445/admin/oauth2/introspect",
+//	})
+//	...
+//	mux.Handle("/courses", validator.Middleware("courses.read")(coursesHandler))
+//
+// Handlers read the verified claims with GetClaimsFrom.
+package resourceserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hawkkiller/oauth2-gateway/auth-gateway/internal/cache"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, expired,
+	// revoked or were not issued for this resource server.
+	ErrInvalidToken = errors.New("invalid access token")
+	// ErrOpaqueTokenUnsupported is returned for opaque tokens when no
+	// introspection URL is configured.
+	ErrOpaqueTokenUnsupported = errors.New("opaque access tokens are not supported")
+)
+
+const (
+	// maxCachedTokens bounds the memory used by the introspection cache.
+	maxCachedTokens = 10_000
+	// tokenUseAccessToken is the token_use Hydra reports for access tokens.
+	tokenUseAccessToken = "access_token"
+)
+
+type Config struct {
+	// Issuer is the expected iss claim, the public Hydra URL.
+	Issuer string
+	// JWKSURL defaults to Issuer + "/.well-known/jwks.json".
+	JWKSURL string
+	// Audience, if set, requires the token to be issued for at least one
+	// of these audiences.
+	Audience []string
+	// IntrospectionURL is the Hydra admin introspection endpoint. Opaque
+	// tokens are rejected when it is empty.
+	IntrospectionURL string
+	// IntrospectionCacheTTL is the longest an introspection result is
+	// reused, a revoked token stops passing after at most this long. It
+	// defaults to 30 seconds, a negative value disables the cache.
+	IntrospectionCacheTTL time.Duration
+	// JWKSRefreshInterval defaults to five minutes.
+	JWKSRefreshInterval time.Duration
+	// Leeway is the allowed clock skew for exp, nbf and iat.
+	Leeway     time.Duration
+	HTTPClient *http.Client
+}
+
+type Validator struct {
+	cfg        Config
+	jwks       *jwks
+	parser     *jwt.Parser
+	httpClient *http.Client
+	tokens     *cache.TTL[string, *Claims]
+}
+
+func NewValidator(cfg Config) (*Validator, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("resourceserver: issuer is required")
+	}
+
+	if cfg.JWKSURL == "" {
+		cfg.JWKSURL = strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/jwks.json"
+	}
+
+	if cfg.JWKSRefreshInterval == 0 {
+		cfg.JWKSRefreshInterval = 5 * time.Minute
+	}
+
+	if cfg.IntrospectionCacheTTL == 0 {
+		cfg.IntrospectionCacheTTL = 30 * time.Second
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(cfg.Leeway),
+	)
+
+	return &Validator{
+		cfg:        cfg,
+		jwks:       newJWKS(cfg.JWKSURL, httpClient, cfg.JWKSRefreshInterval),
+		parser:     parser,
+		httpClient: httpClient,
+		tokens:     cache.NewTTL[string, *Claims](maxCachedTokens),
+	}, nil
+}
+
+// Validate verifies the access token and returns its claims.
+func (v *Validator) Validate(ctx context.Context, token string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var (
+		claims *Claims
+		err    error
+	)
+
+	// Hydra's opaque tokens have two segments, JWTs have three.
+	if strings.Count(token, ".") == 2 {
+		claims, err = v.validateJWT(ctx, token)
+	} else {
+		claims, err = v.introspect(ctx, token)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !v.audienceAllowed(claims.Audience) {
+		return nil, fmt.Errorf("%w: audience not allowed", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// hydraAccessTokenClaims are the claims of a Hydra JWT access token.
+type hydraAccessTokenClaims struct {
+	jwt.RegisteredClaims
+	ClientID string         `json:"client_id"`
+	Scopes   []string       `json:"scp"`
+	Extra    map[string]any `json:"ext"`
+}
+
+// tokenUse tells access tokens from ID tokens signed with the same keys.
+// Only access tokens carry client_id and scp, an ID token has the client
+// as audience instead.
+func (c *hydraAccessTokenClaims) tokenUse() string {
+	if c.ClientID == "" || c.Scopes == nil {
+		return "id_token"
+	}
+
+	return tokenUseAccessToken
+}
+
+// requireAccessToken is the check both validation paths apply, tokens
+// other than access tokens are never accepted as bearer tokens.
+func requireAccessToken(tokenUse, clientID string) error {
+	if tokenUse != tokenUseAccessToken || clientID == "" {
+		return fmt.Errorf("%w: not an access token", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+func (v *Validator) validateJWT(ctx context.Context, token string) (*Claims, error) {
+	var tokenClaims hydraAccessTokenClaims
+
+	_, err := v.parser.ParseWithClaims(token, &tokenClaims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.jwks.key(ctx, kid)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if !v.issuerMatches(tokenClaims.Issuer) {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidToken)
+	}
+
+	if err := requireAccessToken(tokenClaims.tokenUse(), tokenClaims.ClientID); err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{
+		Subject:  tokenClaims.Subject,
+		ClientID: tokenClaims.ClientID,
+		Issuer:   tokenClaims.Issuer,
+		Audience: tokenClaims.Audience,
+		Scopes:   tokenClaims.Scopes,
+		Extra:    tokenClaims.Extra,
+	}
+
+	if tokenClaims.ExpiresAt != nil {
+		claims.ExpiresAt = tokenClaims.ExpiresAt.Time
+	}
+
+	return claims, nil
+}
+
+type introspectionResponse struct {
+	Active   bool           `json:"active"`
+	Subject  string         `json:"sub"`
+	ClientID string         `json:"client_id"`
+	Issuer   string         `json:"iss"`
+	Audience []string       `json:"aud"`
+	Scope    string         `json:"scope"`
+	Exp      int64          `json:"exp"`
+	TokenUse string         `json:"token_use"`
+	Extra    map[string]any `json:"ext"`
+}
+
+// introspect asks Hydra about an opaque token. Active tokens are cached
+// until they expire, but no longer than the IntrospectionCacheTTL.
+func (v *Validator) introspect(ctx context.Context, token string) (*Claims, error) {
+	if v.cfg.IntrospectionURL == "" {
+		return nil, ErrOpaqueTokenUnsupported
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	if claims, ok := v.tokens.Get(key); ok {
+		return claims, nil
+	}
+
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.cfg.IntrospectionURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspect token: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspect token: unexpected status %d", res.StatusCode)
+	}
+
+	var introspected introspectionResponse
+	if err := json.NewDecoder(res.Body).Decode(&introspected); err != nil {
+		return nil, fmt.Errorf("decode introspection: %w", err)
+	}
+
+	if !introspected.Active {
+		return nil, ErrInvalidToken
+	}
+
+	if err := requireAccessToken(introspected.TokenUse, introspected.ClientID); err != nil {
+		return nil, err
+	}
+
+	if introspected.Issuer != "" && !v.issuerMatches(introspected.Issuer) {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidToken)
+	}
+
+	claims := &Claims{
+		Subject:   introspected.Subject,
+		ClientID:  introspected.ClientID,
+		Issuer:    introspected.Issuer,
+		Audience:  introspected.Audience,
+		Scopes:    strings.Fields(introspected.Scope),
+		ExpiresAt: time.Unix(introspected.Exp, 0),
+		Extra:     introspected.Extra,
+	}
+
+	if v.cfg.IntrospectionCacheTTL > 0 {
+		v.tokens.Set(key, claims, cacheUntil(claims.ExpiresAt, time.Now(), v.cfg.IntrospectionCacheTTL))
+	}
+
+	return claims, nil
+}
+
+// cacheUntil returns when a cached introspection result expires, at the
+// token expiry but no later than maxTTL from now.
+func cacheUntil(expiresAt, now time.Time, maxTTL time.Duration) time.Time {
+	if limit := now.Add(maxTTL); limit.Before(expiresAt) {
+		return limit
+	}
+
+	return expiresAt
+}
+
+func (v *Validator) audienceAllowed(audience []string) bool {
+	if len(v.cfg.Audience) == 0 {
+		return true
+	}
+
+	for _, aud := range audience {
+		if slices.Contains(v.cfg.Audience, aud) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// issuerMatches compares issuers ignoring a trailing slash, which Hydra
+// may add to the configured issuer URL.
+func (v *Validator) issuerMatches(issuer string) bool {
+	return strings.TrimSuffix(issuer, "/") == strings.TrimSuffix(v.cfg.Issuer, "/")
+}
@@ -0,0 +1,235 @@
+package resourceserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testIssuer = "http://hydra.test"
+
+// newTestIssuer serves the JWKS of a fresh RSA key and returns a function
+// signing claims with it.
+func newTestIssuer(t *testing.T) (jwksURL string, sign func(jwt.MapClaims) string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []jsonWebKey{{
+				Kid: "test",
+				Kty: "RSA",
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	sign = func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+
+		return signed
+	}
+
+	return server.URL, sign
+}
+
+func TestValidateJWT(t *testing.T) {
+	jwksURL, sign := newTestIssuer(t)
+	now := time.Now()
+
+	accessToken := jwt.MapClaims{
+		"iss":       testIssuer,
+		"sub":       "user",
+		"aud":       []string{},
+		"client_id": "app",
+		"scp":       []string{"openid", "courses.read"},
+		"iat":       now.Unix(),
+		"exp":       now.Add(time.Hour).Unix(),
+	}
+
+	idToken := jwt.MapClaims{
+		"iss": testIssuer,
+		"sub": "user",
+		"aud": []string{"app"},
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+
+	tests := []struct {
+		name     string
+		audience []string
+		claims   jwt.MapClaims
+		wantErr  bool
+	}{
+		{name: "access token", claims: accessToken},
+		{name: "access token without scopes", claims: with(accessToken, "scp", []string{})},
+		{name: "id token", claims: idToken, wantErr: true},
+		{name: "id token for allowed audience", audience: []string{"app"}, claims: idToken, wantErr: true},
+		{name: "missing client_id", claims: without(accessToken, "client_id"), wantErr: true},
+		{name: "missing scp", claims: without(accessToken, "scp"), wantErr: true},
+		{name: "other issuer", claims: with(accessToken, "iss", "http://other.test"), wantErr: true},
+		{name: "expired", claims: with(accessToken, "exp", now.Add(-time.Minute).Unix()), wantErr: true},
+		{name: "audience not allowed", audience: []string{"billing-api"}, claims: accessToken, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewValidator(Config{Issuer: testIssuer, JWKSURL: jwksURL, Audience: tt.audience})
+			if err != nil {
+				t.Fatalf("NewValidator() error = %v", err)
+			}
+
+			claims, err := validator.Validate(context.Background(), sign(tt.claims))
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Validate() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			if claims.Subject != "user" || claims.ClientID != "app" {
+				t.Errorf("Validate() claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		response map[string]any
+		wantErr  bool
+	}{
+		{
+			name:     "access token",
+			response: map[string]any{"active": true, "sub": "user", "client_id": "app", "token_use": "access_token", "exp": exp},
+		},
+		{
+			name:     "inactive",
+			response: map[string]any{"active": false},
+			wantErr:  true,
+		},
+		{
+			name:     "refresh token",
+			response: map[string]any{"active": true, "sub": "user", "client_id": "app", "token_use": "refresh_token", "exp": exp},
+			wantErr:  true,
+		},
+		{
+			name:     "missing client_id",
+			response: map[string]any{"active": true, "sub": "user", "token_use": "access_token", "exp": exp},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_ = json.NewEncoder(w).Encode(tt.response)
+			}))
+			defer server.Close()
+
+			validator, err := NewValidator(Config{Issuer: testIssuer, IntrospectionURL: server.URL})
+			if err != nil {
+				t.Fatalf("NewValidator() error = %v", err)
+			}
+
+			_, err = validator.Validate(context.Background(), "opaque.token")
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIntrospectCacheTTL(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"active":    true,
+			"sub":       "user",
+			"client_id": "app",
+			"token_use": "access_token",
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		})
+	}))
+	defer server.Close()
+
+	validator, err := NewValidator(Config{
+		Issuer:                testIssuer,
+		IntrospectionURL:      server.URL,
+		IntrospectionCacheTTL: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewValidator() error = %v", err)
+	}
+
+	for range 2 {
+		if _, err := validator.Validate(context.Background(), "opaque.token"); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("introspection calls = %d, want 1 while cached", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := validator.Validate(context.Background(), "opaque.token"); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("introspection calls = %d, want 2 after the cache TTL", got)
+	}
+}
+
+func with(claims jwt.MapClaims, key string, value any) jwt.MapClaims {
+	result := make(jwt.MapClaims, len(claims))
+	for k, v := range claims {
+		result[k] = v
+	}
+
+	result[key] = value
+	return result
+}
+
+func without(claims jwt.MapClaims, key string) jwt.MapClaims {
+	result := with(claims, key, nil)
+	delete(result, key)
+	return result
+}
@@ -2,8 +2,9 @@
 
 # Configuration
 AUTH_URL="http://auth.learny.local/api/oauth2/auth"
-REDIRECT_URI="http://127.0.0.1:5555/callback" # Redirect to UI after login
-CLIENT_ID="19c0216a-0b1a-4486-96a2-37ebc44d0339"
+REDIRECT_URI="http://localhost:5555/callback" # Redirect to UI after login
+# Client ID as returned by POST /api/admin/clients
+CLIENT_ID="${CLIENT_ID:?CLIENT_ID must be set}"
 SCOPE="openid%20offline"
 
 # Generate PKCE values